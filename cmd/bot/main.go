@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"js-bet/pkg/client"
+	"log"
+	"os"
+	"os/signal"
+)
+
+/*
+Damage a fighter is expected to deal per tick to an enemy with the given dodge rate
+
+Attacks land with the fighter's accuracy minus the enemy's dodge, crits multiply the damage and the attack
+timer runs down by the fighter's speed every tick
+*/
+func expectedDPS(f client.Fighter, enemyDodge float32) float64 {
+	if !f.Alive || f.MaxAttackTimer == 0 {
+		return 0
+	}
+	hitChance := max(float64(f.Accuracy-enemyDodge), 0)
+	perHit := float64(f.Damage) * (1 + float64(f.CritRate)*(client.CRIT_MULTIPLIER-1))
+	attacksPerTick := float64(f.Speed) / float64(f.MaxAttackTimer)
+	return hitChance * perHit * attacksPerTick
+}
+
+// Combined expected DPS of a team against the average dodge of the other team
+func teamDPS(team client.Team, enemies client.Team) float64 {
+	var dodge float32
+	for _, enemy := range enemies.Members {
+		dodge += enemy.Dodge
+	}
+	dodge /= float32(max(len(enemies.Members), 1))
+
+	total := 0.0
+	for _, member := range team.Members {
+		total += expectedDPS(member, dodge)
+	}
+	return total
+}
+
+func main() {
+	url := flag.String("url", "http://localhost:8080", "Address of the js.bet server")
+	name := flag.String("name", "dps-bot", "Account the bot bets with, created on first login")
+	pass := flag.String("pass", "", "Password of the account")
+	apiKey := flag.String("key", os.Getenv("JSBET_API_KEY"), "API key with the betting scope, used instead of logging in")
+	amount := flag.Int("amount", 5, "Gold bet on every duel")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := client.New(*url)
+	c.OnStreamError = func(err error) {
+		log.Printf("Stream dropped, reconnecting: %v", err)
+	}
+	if *apiKey != "" {
+		c.UseAPIKey(*apiKey)
+	} else if err := c.Login(ctx, *name, *pass); err != nil {
+		log.Fatalf("Unable to log in: %v", err)
+	}
+
+	states := make(chan client.State)
+	go c.Stream(ctx, states)
+
+	// Bet once per round, reported once the round is over. A refused bet is not tried again until the next round
+	attempted, betPlaced := false, false
+	for state := range states {
+		switch {
+		case state.Phase == client.PREROUND && state.BettingOpen && !attempted && !state.FreeForAll() && len(state.Teams) == 2:
+			attempted = true
+			left := teamDPS(state.Teams[0], state.Teams[1])
+			right := teamDPS(state.Teams[1], state.Teams[0])
+			bet, err := c.BetOnSide(ctx, left >= right, *amount)
+			if err != nil {
+				log.Printf("Unable to place bet: %v", err)
+				continue
+			}
+			betPlaced = true
+			log.Printf("Bet %d on %s (expected DPS %.2f vs %.2f)", bet.Amount, bet.Side, left, right)
+		case state.Phase == client.POSTROUND && attempted:
+			attempted = false
+			if !betPlaced {
+				continue
+			}
+			betPlaced = false
+			gold, err := c.Balance(ctx)
+			if err != nil {
+				log.Printf("Unable to get balance: %v", err)
+				continue
+			}
+			log.Printf("%s won the round, balance is now %d", state.Winner, gold)
+		}
+	}
+}
@@ -1,9 +1,98 @@
 package main
 
 import (
+	"flag"
 	"js-bet/internal"
+	"js-bet/internal/game"
+	"log"
+	"os"
+	"strings"
+	"time"
 )
 
 func main() {
-	internal.StartServer()
+	tournament := flag.String("tournament", "", "Run the arena as a tournament bracket, either 'single' or 'double' elimination")
+	matchmaking := flag.String("matchmaking", "random", "Policy used to pick challengers: 'random', 'closest' or 'gatekeeper'")
+	teamSize := flag.Int("team-size", 1, "Number of fighters on each side, 2 and 3 play team battles")
+	royaleSize := flag.Int("royale", 0, "Run a battle royale between 4 to 7 fighters instead of duels")
+	admins := flag.String("admins", "", "Comma separated names of users to promote to admins")
+	arena := flag.String("arena", "main", "Name of the arena, attached to everything the game logs")
+	staticDir := flag.String("static-dir", "", "Serve static files from this directory instead of the ones embedded in the binary")
+	dev := flag.Bool("dev", false, "Watch static files and components while developing, serving static files from ./static unless -static-dir is set")
+	prod := flag.Bool("production", false, "Mark cookies secure and send HSTS, for sites served over https, signing keys are read from $"+internal.SIGNING_KEYS_ENV)
+	loginRate := flag.Int("login-rate", 10, "Login attempts accepted per minute from one address, 0 for no limit")
+	signupRate := flag.Int("signup-rate", 3, "Accounts created per hour from one address, 0 for no limit")
+	betRate := flag.Int("bet-rate", 30, "Bets accepted per minute from one user or address, 0 for no limit")
+	lockoutAfter := flag.Int("lockout-after", 5, "Failed logins in a row before an account is locked, 0 to never lock accounts")
+	lockoutDuration := flag.Duration("lockout-duration", 15*time.Minute, "How long accounts stay locked after too many failed logins")
+	logFormat := flag.String("log-format", "text", "Format of the logs, either 'text' or 'json'")
+	logLevel := flag.String("log-level", "info", "Least severe level logged: 'debug', 'info', 'warn' or 'error'")
+	flag.Parse()
+
+	if err := internal.ConfigureLogging(os.Stderr, *logFormat, *logLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	cfg := internal.Config{Arena: *arena, StaticDir: *staticDir, Dev: *dev, Production: *prod}
+	cfg.RateLimits = internal.RateLimits{
+		LoginsPerMinute: *loginRate,
+		SignupsPerHour:  *signupRate,
+		BetsPerMinute:   *betRate,
+		LockoutAfter:    *lockoutAfter,
+		LockoutDuration: *lockoutDuration,
+	}
+	if keys := os.Getenv(internal.SIGNING_KEYS_ENV); keys != "" {
+		signingKeys, err := internal.ParseSigningKeys(keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cfg.SigningKeys = signingKeys
+	}
+	if *dev && cfg.StaticDir == "" {
+		cfg.StaticDir = "static"
+	}
+	switch *tournament {
+	case "":
+	case "single":
+		cfg.TournamentFormat = game.SINGLE_ELIMINATION
+	case "double":
+		cfg.TournamentFormat = game.DOUBLE_ELIMINATION
+	default:
+		log.Fatalf("Unknown tournament format '%s', expected 'single' or 'double'", *tournament)
+	}
+
+	switch *matchmaking {
+	case "random":
+		cfg.Matchmaker = game.UniformRandom{}
+	case "closest":
+		cfg.Matchmaker = game.ClosestRating{}
+	case "gatekeeper":
+		cfg.Matchmaker = game.Gatekeeper{}
+	default:
+		log.Fatalf("Unknown matchmaking policy '%s', expected 'random', 'closest' or 'gatekeeper'", *matchmaking)
+	}
+
+	if *teamSize < 1 || *teamSize > 3 {
+		log.Fatalf("Team size must be between 1 and 3, got %d", *teamSize)
+	}
+	if *teamSize > 1 && cfg.TournamentFormat != 0 {
+		log.Fatal("Tournaments are only played one-vs-one, team size cannot be combined with -tournament")
+	}
+	cfg.TeamSize = *teamSize
+
+	if *royaleSize != 0 {
+		if *royaleSize < 4 || *royaleSize > 7 {
+			log.Fatalf("Battle royales are fought between 4 and 7 fighters, got %d", *royaleSize)
+		}
+		if *teamSize > 1 || cfg.TournamentFormat != 0 {
+			log.Fatal("Battle royales cannot be combined with -team-size or -tournament")
+		}
+		cfg.RoyaleSize = *royaleSize
+	}
+
+	if *admins != "" {
+		cfg.Admins = strings.Split(*admins, ",")
+	}
+
+	internal.StartServer(cfg)
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"js-bet/pkg/client"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Gold added or taken from the bet amount by the + and - keys
+const AMOUNT_STEP = 5
+
+type key uint
+
+const (
+	_         = iota
+	KEY_LEFT  // 1
+	KEY_RIGHT // 2
+	KEY_MORE  // 3
+	KEY_LESS  // 4
+	KEY_QUIT  // 5
+)
+
+// Read key presses from the terminal in raw mode, arrow keys arrive as escape sequences
+func readKeys(keys chan<- key) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			keys <- KEY_QUIT
+			return
+		}
+		switch input := string(buf[:n]); input {
+		case "l", "L", "\x1b[D":
+			keys <- KEY_LEFT
+		case "r", "R", "\x1b[C":
+			keys <- KEY_RIGHT
+		case "+", "=":
+			keys <- KEY_MORE
+		case "-", "_":
+			keys <- KEY_LESS
+		case "q", "Q", "\x03", "\x04":
+			keys <- KEY_QUIT
+		}
+	}
+}
+
+func main() {
+	url := flag.String("url", "http://localhost:8080", "Address of the js.bet server")
+	name := flag.String("name", "", "Account to bet with, leave empty to only spectate")
+	pass := flag.String("pass", "", "Password of the account")
+	apiKey := flag.String("key", os.Getenv("JSBET_API_KEY"), "API key with the betting scope, used instead of logging in")
+	amount := flag.Int("amount", 5, "Gold bet when pressing a bet key")
+	flag.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := client.New(*url)
+	v := view{amount: *amount, gold: -1}
+	switch {
+	case *apiKey != "":
+		c.UseAPIKey(*apiKey)
+	case *name != "":
+		if err := c.Login(ctx, *name, *pass); err != nil {
+			log.Fatalf("Unable to log in: %v", err)
+		}
+	}
+	if *apiKey != "" || *name != "" {
+		gold, err := c.Balance(ctx)
+		if err != nil {
+			log.Fatalf("Unable to get balance: %v", err)
+		}
+		v.gold = gold
+	}
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		log.Fatalf("Unable to put the terminal in raw mode: %v", err)
+	}
+	fmt.Print(ALT_SCREEN)
+	defer func() {
+		fmt.Print(MAIN_SCREEN)
+		term.Restore(int(os.Stdin.Fd()), oldState)
+	}()
+
+	states := make(chan client.State)
+	keys := make(chan key)
+	messages := make(chan string)
+	balances := make(chan int)
+	c.OnStreamError = func(err error) {
+		go func() { messages <- fmt.Sprintf("Stream dropped, reconnecting: %v", err) }()
+	}
+	go c.Stream(ctx, states)
+	go readKeys(keys)
+
+	// Balances change when bets are settled at the end of a round
+	refreshBalance := func() {
+		go func() {
+			gold, err := c.Balance(ctx)
+			if err != nil {
+				messages <- fmt.Sprintf("Unable to get balance: %v", err)
+				return
+			}
+			balances <- gold
+		}()
+	}
+	placeBet := func(left bool) {
+		if v.gold < 0 {
+			v.message = "Log in with -name or -key to place bets"
+			return
+		}
+		amount := v.amount
+		go func() {
+			bet, err := c.BetOnSide(ctx, left, amount)
+			if err != nil {
+				messages <- fmt.Sprintf("Unable to place bet: %v", err)
+				return
+			}
+			messages <- fmt.Sprintf("Placed %d on %s", bet.Amount, bet.Side)
+		}()
+	}
+
+	redraw := time.NewTicker(time.Second)
+	defer redraw.Stop()
+	lastPhase := client.Phase("")
+	for {
+		select {
+		case state, ok := <-states:
+			if !ok {
+				return
+			}
+			if state.Phase == client.POSTROUND && lastPhase != client.POSTROUND && v.gold >= 0 {
+				refreshBalance()
+			}
+			lastPhase = state.Phase
+			v.state = state
+		case k := <-keys:
+			switch k {
+			case KEY_LEFT:
+				placeBet(true)
+			case KEY_RIGHT:
+				placeBet(false)
+			case KEY_MORE:
+				v.amount += AMOUNT_STEP
+			case KEY_LESS:
+				v.amount = max(v.amount-AMOUNT_STEP, AMOUNT_STEP)
+			case KEY_QUIT:
+				return
+			}
+		case message := <-messages:
+			v.message = message
+		case gold := <-balances:
+			v.gold = gold
+		case <-redraw.C:
+			// Keep up with terminal resizes between states
+		}
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		render(os.Stdout, v, width, height)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"js-bet/pkg/client"
+	"strings"
+)
+
+const BAR_WIDTH = 20
+
+// ANSI escapes used to draw the screen
+const (
+	CLEAR       = "\x1b[H\x1b[2J"
+	BOLD        = "\x1b[1m"
+	DIM         = "\x1b[2m"
+	GREEN       = "\x1b[32m"
+	RED         = "\x1b[31m"
+	YELLOW      = "\x1b[33m"
+	RESET       = "\x1b[0m"
+	ALT_SCREEN  = "\x1b[?1049h\x1b[?25l"
+	MAIN_SCREEN = "\x1b[?25h\x1b[?1049l"
+)
+
+// Everything shown on screen
+type view struct {
+	state   client.State
+	amount  int    // Gold bet when pressing a bet key
+	gold    int    // Balance of the logged in account, -1 when spectating without an account
+	message string // Outcome of the latest bet or error
+}
+
+// Bar of width cells filled to the fraction of value over maxValue
+func bar(value int, maxValue int, width int, color string) string {
+	filled := 0
+	if maxValue > 0 {
+		filled = min(max(value, 0)*width/maxValue, width)
+	}
+	return color + strings.Repeat("█", filled) + DIM + strings.Repeat("░", width-filled) + RESET
+}
+
+func fighterLines(f client.Fighter) []string {
+	name := BOLD + f.Name + RESET
+	if !f.Alive {
+		name = DIM + f.Name + " (down)" + RESET
+	}
+	rating := fmt.Sprintf("Rating %d", f.Rating)
+	if f.RatingChange != 0 {
+		rating += fmt.Sprintf(" (%+d)", f.RatingChange)
+	}
+	return []string{
+		name,
+		fmt.Sprintf("HP    %s %d/%d", bar(f.Health, f.MaxHealth, BAR_WIDTH, GREEN), f.Health, f.MaxHealth),
+		fmt.Sprintf("Timer %s %d/%d", bar(f.AttackTimer, f.MaxAttackTimer, BAR_WIDTH, YELLOW), f.AttackTimer, f.MaxAttackTimer),
+		rating,
+	}
+}
+
+// Width of a line as shown in the terminal, skipping escape sequences
+func visibleWidth(s string) int {
+	width := 0
+	escaped := false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			escaped = true
+		case escaped:
+			escaped = r != 'm'
+		default:
+			width += 1
+		}
+	}
+	return width
+}
+
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-visibleWidth(s), 0))
+}
+
+func header(v view) []string {
+	s := v.state
+	lines := []string{BOLD + "js.bet" + RESET + "  " + s.Status}
+	phase := ""
+	switch s.Phase {
+	case client.PREROUND:
+		phase = fmt.Sprintf("Round starts in %ds", s.Countdown)
+	case client.POSTROUND:
+		phase = fmt.Sprintf("Next round in %ds", s.Countdown)
+	case client.ROUND:
+		phase = "Fighting"
+	}
+	betting := RED + "Betting closed" + RESET
+	if s.BettingOpen {
+		betting = GREEN + "Betting open" + RESET
+	}
+	lines = append(lines, phase+"  "+betting)
+	if s.Paused {
+		lines = append(lines, YELLOW+"The arena is paused"+RESET)
+	}
+	if s.Announcement != "" {
+		lines = append(lines, YELLOW+s.Announcement+RESET)
+	}
+	if v.gold >= 0 {
+		lines = append(lines, fmt.Sprintf("Gold %d  Bet amount %d", v.gold, v.amount))
+	}
+	return lines
+}
+
+// Teams side by side in a duel, one after another in a battle royale
+func arena(s client.State, width int) []string {
+	if !s.FreeForAll() && len(s.Teams) == 2 {
+		left, right := []string{}, []string{}
+		for _, member := range s.Teams[0].Members {
+			left = append(append(left, fighterLines(member)...), "")
+		}
+		for _, member := range s.Teams[1].Members {
+			right = append(append(right, fighterLines(member)...), "")
+		}
+		column := max(width/2, 40)
+		lines := []string{}
+		for i := range max(len(left), len(right)) {
+			var l, r string
+			if i < len(left) {
+				l = left[i]
+			}
+			if i < len(right) {
+				r = right[i]
+			}
+			lines = append(lines, pad(l, column)+r)
+		}
+		return lines
+	}
+
+	lines := []string{}
+	for _, team := range s.Teams {
+		for _, member := range team.Members {
+			fighter := fighterLines(member)
+			if team.Placement != 0 {
+				fighter[0] += fmt.Sprintf("  #%d", team.Placement)
+			}
+			lines = append(lines, fighter[0]+"  "+fighter[1])
+		}
+	}
+	return append(lines, "")
+}
+
+func footer(v view) []string {
+	keys := "[q] quit"
+	if v.gold >= 0 {
+		keys = "[←/l] bet left  [→/r] bet right  [+/-] amount  " + keys
+	}
+	lines := []string{}
+	if v.message != "" {
+		lines = append(lines, v.message)
+	}
+	return append(lines, DIM+keys+RESET)
+}
+
+// Draw the whole screen, the event log fills whatever height is left and scrolls to the latest entries
+func render(w io.Writer, v view, width int, height int) {
+	top := append(header(v), "")
+	top = append(top, arena(v.state, width)...)
+	bottom := footer(v)
+
+	logHeight := max(height-len(top)-len(bottom)-1, 0)
+	events := v.state.Events[max(len(v.state.Events)-logHeight, 0):]
+
+	var screen strings.Builder
+	screen.WriteString(CLEAR)
+	for _, line := range top {
+		screen.WriteString(line + "\r\n")
+	}
+	for _, event := range events {
+		screen.WriteString(DIM + event + RESET + "\r\n")
+	}
+	for range logHeight - len(events) {
+		screen.WriteString("\r\n")
+	}
+	screen.WriteString(strings.Join(bottom, "\r\n"))
+	io.WriteString(w, screen.String())
+}
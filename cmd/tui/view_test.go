@@ -0,0 +1,72 @@
+package main
+
+import (
+	"js-bet/pkg/client"
+	"strings"
+	"testing"
+)
+
+func TestBar(t *testing.T) {
+	cases := []struct {
+		value, maxValue, filled int
+	}{
+		{5, 10, 5},
+		{-3, 10, 0},  // Negative values leave the bar empty
+		{15, 10, 10}, // Values past the maximum fill the whole bar
+		{5, 0, 0},
+	}
+	for _, c := range cases {
+		b := bar(c.value, c.maxValue, 10, GREEN)
+		if filled := strings.Count(b, "█"); filled != c.filled {
+			t.Errorf("bar(%d, %d) filled %d cells, expected %d", c.value, c.maxValue, filled, c.filled)
+		}
+		if width := visibleWidth(b); width != 10 {
+			t.Errorf("bar(%d, %d) is %d cells wide, expected 10", c.value, c.maxValue, width)
+		}
+	}
+}
+
+func TestVisibleWidth(t *testing.T) {
+	cases := map[string]int{
+		"":                               0,
+		"plain":                          5,
+		BOLD + "js.bet" + RESET:          6,
+		GREEN + "██" + DIM + "░" + RESET: 3,
+	}
+	for s, expected := range cases {
+		if width := visibleWidth(s); width != expected {
+			t.Errorf("visibleWidth(%q) = %d, expected %d", s, width, expected)
+		}
+	}
+	if padded := pad(BOLD+"ab"+RESET, 4); visibleWidth(padded) != 4 || !strings.HasSuffix(padded, RESET+"  ") {
+		t.Errorf("expected padding after the escapes, got %q", padded)
+	}
+}
+
+func TestRenderCropsEventLog(t *testing.T) {
+	v := view{
+		state: client.State{Events: []string{"first", "second", "third"}},
+		gold:  -1,
+	}
+	screen := func(height int) string {
+		var out strings.Builder
+		render(&out, v, 80, height)
+		return out.String()
+	}
+	top := len(header(v)) + 1 + len(arena(v.state, 80))
+	bottom := len(footer(v))
+
+	tall := screen(top + bottom + 1 + 2)
+	if strings.Contains(tall, "first") || !strings.Contains(tall, "second") || !strings.Contains(tall, "third") {
+		t.Errorf("expected only the latest two events to fit, got %q", tall)
+	}
+	short := screen(top)
+	for _, event := range v.state.Events {
+		if strings.Contains(short, event) {
+			t.Errorf("expected no room for the event log when the terminal is shorter than the header and footer, got %q", short)
+		}
+	}
+	if lines := strings.Count(short, "\r\n") + 1; lines != top+bottom {
+		t.Errorf("expected only the header and footer to be drawn, got %d lines", lines)
+	}
+}
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"js-bet/internal/components"
+	"js-bet/internal/game"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// How long an admin waits for the game loop to pick up a command before giving up
+const ARENA_COMMAND_TIMEOUT = 3 * time.Second
+
+// Entries of the gold ledger shown on the admin console
+const ADMIN_LEDGER_ENTRIES = 20
+
+// Change to the running game, applied by runGame between ticks so the game is only touched by one goroutine
+type arenaCommand struct {
+	apply func(gs *game.GameState) error
+	done  chan error
+}
+
+var arenaCommands = make(chan arenaCommand)
+
+// Arena controls set by admins and read by runGame every tick
+var arenaPaused atomic.Bool
+var announcement atomic.Value // Text broadcast above the arena, empty when there is none
+
+func currentAnnouncement() string {
+	text, _ := announcement.Load().(string)
+	return text
+}
+
+// Run a command against the game being played, waiting for runGame to apply it
+func commandArena(apply func(gs *game.GameState) error) error {
+	command := arenaCommand{apply, make(chan error, 1)}
+	select {
+	case arenaCommands <- command:
+	case <-time.After(ARENA_COMMAND_TIMEOUT):
+		return fmt.Errorf("error: the arena is not running")
+	}
+	return <-command.done
+}
+
+func writeAdminResult(w http.ResponseWriter, message string, ok bool) {
+	w.Header().Set("Content-Type", "text/html")
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := components.AdminResult(message, ok).Render(context.Background(), w); err != nil {
+		slog.Error("Unable to render admin result", "err", err)
+	}
+}
+
+func handleAdminConsole(w http.ResponseWriter, r *http.Request) {
+	view := components.AdminConsoleView{
+		Paused:       arenaPaused.Load(),
+		Announcement: currentAnnouncement(),
+		Fighters:     game.RosterNames(),
+	}
+	if err := commandArena(func(gs *game.GameState) error {
+		view.MatchupSize = len(gs.Teams) * gs.TeamSize
+		view.Tournament = gs.Tournament != nil
+		return nil
+	}); err != nil {
+		requestLogger(r).Warn("Unable to read the running game", "err", err)
+	}
+	ledger, err := db.GoldLedger(ADMIN_LEDGER_ENTRIES)
+	if err != nil {
+		requestLogger(r).Error("Unable to load gold ledger", "err", err)
+	}
+	for _, entry := range ledger {
+		view.Ledger = append(view.Ledger, components.LedgerRow{
+			User:   entry.UserName,
+			Amount: entry.Amount,
+			Reason: entry.Reason,
+			Admin:  entry.AdminName,
+			At:     entry.CreatedAt,
+		})
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if err = components.AdminConsole(view, siteAssets()).Render(context.Background(), w); err != nil {
+		requestLogger(r).Error("Unable to render admin console", "err", err)
+	}
+}
+
+/*
+Admin console for operating the live arena, served under /admin/
+
+GET /admin/ shows the console, every other action is a POST from one of its forms answered with a short
+result for the console to show
+*/
+func handleAdmin(w http.ResponseWriter, r *http.Request) {
+	action := strings.TrimPrefix(r.URL.Path, "/admin/")
+	if action == "" {
+		handleAdminConsole(w, r)
+		return
+	}
+	r.ParseForm()
+	adminID, _ := r.Context().Value(userIDKey).(int64)
+	adminName, _ := r.Context().Value(userNameKey).(string)
+	logger := requestLogger(r).With("admin", adminName)
+
+	switch action {
+	case "pause":
+		arenaPaused.Store(true)
+		logger.Info("Arena paused")
+		writeAdminResult(w, "Arena paused", true)
+	case "resume":
+		arenaPaused.Store(false)
+		logger.Info("Arena resumed")
+		writeAdminResult(w, "Arena resumed", true)
+	case "endRound":
+		reason := r.FormValue("reason")
+		if reason == "" {
+			reason = "Round called off by an admin"
+		}
+		if err := commandArena(func(gs *game.GameState) error {
+			return gs.AbortRound(reason)
+		}); err != nil {
+			writeAdminResult(w, err.Error(), false)
+			return
+		}
+		logger.Info("Round ended by an admin", "reason", reason)
+		writeAdminResult(w, "Round ended, its bets are void", true)
+	case "matchup":
+		fighters := r.Form["fighter"]
+		if err := commandArena(func(gs *game.GameState) error {
+			return gs.SetNextMatchup(fighters)
+		}); err != nil {
+			writeAdminResult(w, err.Error(), false)
+			return
+		}
+		logger.Info("Next matchup picked", "fighters", fighters)
+		writeAdminResult(w, "Next round: "+strings.Join(fighters, ", "), true)
+	case "gold":
+		name, reason := r.FormValue("name"), strings.TrimSpace(r.FormValue("reason"))
+		amount, err := strconv.Atoi(r.FormValue("amount"))
+		if err != nil || amount == 0 {
+			writeAdminResult(w, "Amount must be a whole number other than zero", false)
+			return
+		}
+		if reason == "" {
+			writeAdminResult(w, "A reason is needed for the ledger", false)
+			return
+		}
+		found, err := db.AdjustUserGold(name, amount, reason, adminID)
+		if err != nil {
+			logger.Error("Unable to adjust gold", "user", name, "err", err)
+			writeAdminResult(w, "Unable to adjust gold", false)
+			return
+		} else if !found {
+			writeAdminResult(w, fmt.Sprintf("No user named %s", name), false)
+			return
+		}
+		logger.Info("Gold adjusted", "user", name, "amount", amount, "reason", reason)
+		writeAdminResult(w, fmt.Sprintf("Changed the gold of %s by %+d", name, amount), true)
+	case "ban":
+		name := r.FormValue("name")
+		banned := r.FormValue("banned") != "false"
+		if name == adminName {
+			writeAdminResult(w, "Admins cannot ban themselves", false)
+			return
+		}
+		found, err := db.SetUserBanned(name, banned)
+		if err != nil {
+			logger.Error("Unable to ban user", "user", name, "err", err)
+			writeAdminResult(w, "Unable to ban user", false)
+			return
+		} else if !found {
+			writeAdminResult(w, fmt.Sprintf("No user named %s", name), false)
+			return
+		}
+		verb := "banned"
+		if !banned {
+			verb = "unbanned"
+		}
+		logger.Info("User "+verb, "user", name)
+		writeAdminResult(w, fmt.Sprintf("%s is %s", name, verb), true)
+	case "announce":
+		text := strings.TrimSpace(r.FormValue("text"))
+		announcement.Store(text)
+		if text == "" {
+			writeAdminResult(w, "Announcement cleared", true)
+			return
+		}
+		logger.Info("Announcement broadcast", "text", text)
+		writeAdminResult(w, "Announcement broadcast", true)
+	default:
+		http.Error(w, "Unknown admin action", http.StatusNotFound)
+	}
+}
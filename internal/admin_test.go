@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"js-bet/internal/game"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func postAdmin(adminID int64, action string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/admin/"+action, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := context.WithValue(r.Context(), userIDKey, adminID)
+	ctx = context.WithValue(ctx, userNameKey, "admin")
+	w := httptest.NewRecorder()
+	handleAdmin(w, r.WithContext(ctx))
+	return w
+}
+
+func TestAdminGoldAndBans(t *testing.T) {
+	useTestDB(t)
+	adminID, _ := db.CheckAddUser("admin", "pass")
+	userID, _ := db.CheckAddUser("player", "pass")
+
+	if w := postAdmin(adminID, "gold", url.Values{"name": {"player"}, "amount": {"15"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("gold should not change without a reason, got %d", w.Code)
+	}
+	if w := postAdmin(adminID, "gold", url.Values{"name": {"player"}, "amount": {"15"}, "reason": {"Refund"}}); w.Code != http.StatusOK {
+		t.Fatalf("expected the gold to be adjusted, got %d: %s", w.Code, w.Body)
+	}
+	if gold, _ := db.GetUserGold("player"); gold != DefaultGold+15 {
+		t.Errorf("expected %d gold, got %d", DefaultGold+15, gold)
+	}
+	ledger, err := db.GoldLedger(10)
+	if err != nil || len(ledger) != 1 || ledger[0].Reason != "Refund" || ledger[0].AdminName != "admin" {
+		t.Errorf("expected the change in the ledger, got %+v (%v)", ledger, err)
+	}
+
+	token := loginAs(t, userID, "player").Access
+	me := authMiddlewareStrict(http.HandlerFunc(handleAPIMe))
+	request := func() int {
+		r := httptest.NewRequest(http.MethodGet, API_PREFIX+"/me", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		me.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("expected the player to be let in, got %d", code)
+	}
+	if w := postAdmin(adminID, "ban", url.Values{"name": {"player"}}); w.Code != http.StatusOK {
+		t.Fatalf("expected the player to be banned, got %d: %s", w.Code, w.Body)
+	}
+	if code := request(); code != http.StatusForbidden {
+		t.Errorf("banned players should be turned away even with a valid token, got %d", code)
+	}
+	postAdmin(adminID, "ban", url.Values{"name": {"player"}, "banned": {"false"}})
+	if code := request(); code != http.StatusOK {
+		t.Errorf("unbanned players should be let in again, got %d", code)
+	}
+}
+
+func TestAdminEndRound(t *testing.T) {
+	useTestDB(t)
+	db.CheckAddUser("player", "pass")
+	defer ClearBets()
+	gs := game.New()
+	gs.StepGame()
+	dispatchLifecycleEvents(&gs)
+	if err := SetBet("player", 10, true); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan bool)
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case command := <-arenaCommands:
+				command.done <- command.apply(&gs)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	if w := postAdmin(0, "endRound", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected the round to end, got %d: %s", w.Code, w.Body)
+	}
+	dispatchLifecycleEvents(&gs)
+	if gs.Phase != game.POSTROUND || len(Bets) != 0 {
+		t.Errorf("expected a post-round phase with void bets, got phase %v and bets %v", gs.Phase, Bets)
+	}
+	if w := postAdmin(0, "endRound", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("a round that is already over should not be called off, got %d", w.Code)
+	}
+	if w := postAdmin(0, "matchup", url.Values{"fighter": {"React"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("a duel should need two fighters, got %d", w.Code)
+	}
+}
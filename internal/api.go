@@ -0,0 +1,447 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"js-bet/internal/eventlog"
+	"js-bet/internal/game"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+Version 1 of the JSON API for third-party clients such as terminal clients and bots
+
+The views below mirror the game state without the parts only the engine needs, such as audio players,
+pending lifecycle events and ability functions, so the wire format stays stable as the engine changes
+*/
+const API_PREFIX = "/api/v1"
+
+type APIAbility struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Cooldown    int    `json:"cooldown"`
+	MaxCooldown int    `json:"maxCooldown"`
+	Priority    int    `json:"priority"`
+}
+
+type APIFighter struct {
+	Name           string       `json:"name"`
+	Color          string       `json:"color"`
+	Health         int          `json:"health"`
+	MaxHealth      int          `json:"maxHealth"`
+	Damage         int          `json:"damage"`
+	Speed          int          `json:"speed"`
+	Accuracy       float32      `json:"accuracy"`
+	Dodge          float32      `json:"dodge"`
+	CritRate       float32      `json:"critRate"`
+	AttackTimer    int          `json:"attackTimer"`
+	MaxAttackTimer int          `json:"maxAttackTimer"`
+	Rating         int          `json:"rating"`
+	RatingChange   int          `json:"ratingChange"`
+	Alive          bool         `json:"alive"`
+	Abilities      []APIAbility `json:"abilities"`
+}
+
+type APITeam struct {
+	Members     []APIFighter `json:"members"`
+	Placement   int          `json:"placement"`   // 1 for the winner, 0 while the team is still standing
+	Challenging bool         `json:"challenging"` // Newly drawn for the upcoming round
+}
+
+type APIMatch struct {
+	Bracket string `json:"bracket"`
+	Round   int    `json:"round"`
+	Left    string `json:"left"`
+	Right   string `json:"right"`
+	Winner  string `json:"winner"`
+}
+
+type APITournament struct {
+	ID       int64      `json:"id"`
+	Round    int        `json:"round"`
+	Entrants []string   `json:"entrants"`
+	Matches  []APIMatch `json:"matches"`
+	Current  int        `json:"current"`
+	Champion string     `json:"champion"`
+}
+
+type APIState struct {
+	Frame        int            `json:"frame"`
+	Phase        string         `json:"phase"`
+	Countdown    int            `json:"countdown"` // Seconds left in the pre-round or post-round phase
+	Status       string         `json:"status"`
+	BettingOpen  bool           `json:"bettingOpen"`
+	Winner       string         `json:"winner"` // Winning side of a duel, empty until one is declared
+	TeamSize     int            `json:"teamSize"`
+	Teams        []APITeam      `json:"teams"`
+	Tournament   *APITournament `json:"tournament,omitempty"`
+	Events       []string       `json:"events"`       // Latest entries of the event log, oldest first
+	EventCount   int            `json:"eventCount"`   // Entries written to the event log so far, to tell which entries are new
+	Paused       bool           `json:"paused"`       // Paused by an admin, the game does not move on until it is resumed
+	Announcement string         `json:"announcement"` // Message broadcast by the admins, empty when there is none
+}
+
+// Number of event log entries sent with every state
+const API_EVENTS = 20
+
+func newAPIFighter(f game.Fighter) APIFighter {
+	abilities := make([]APIAbility, 0, len(f.Abilities))
+	for _, ability := range f.Abilities {
+		abilities = append(abilities, APIAbility{
+			Name:        ability.Name,
+			Description: ability.Description,
+			Cooldown:    ability.Timer.Value,
+			MaxCooldown: ability.Timer.MaxValue,
+			Priority:    ability.Priority,
+		})
+	}
+	return APIFighter{
+		Name:           f.Name,
+		Color:          f.Color,
+		Health:         f.Health.Value,
+		MaxHealth:      f.Health.MaxValue,
+		Damage:         f.Damage.Value,
+		Speed:          f.Speed.Value,
+		Accuracy:       f.Accuracy.Value,
+		Dodge:          f.Dodge.Value,
+		CritRate:       f.CritRate.Value,
+		AttackTimer:    f.AttackTimer.Value,
+		MaxAttackTimer: f.AttackTimer.MaxValue,
+		Rating:         f.Rating.Value,
+		RatingChange:   f.Rating.Change,
+		Alive:          f.Alive(),
+		Abilities:      abilities,
+	}
+}
+
+func newAPIState(gs game.GameState) APIState {
+	state := APIState{
+		Frame:        gs.FrameCount,
+		Phase:        gs.Phase.String(),
+		Countdown:    gs.Countdown(),
+		Status:       gs.Status,
+		BettingOpen:  gs.BettingOpen,
+		Winner:       gs.Winner.String(),
+		TeamSize:     gs.TeamSize,
+		Teams:        make([]APITeam, 0, len(gs.Teams)),
+		Events:       eventlog.EventLog.Log[max(len(eventlog.EventLog.Log)-API_EVENTS, 0):],
+		EventCount:   len(eventlog.EventLog.Log),
+		Paused:       arenaPaused.Load(),
+		Announcement: currentAnnouncement(),
+	}
+	for i, team := range gs.Teams {
+		members := make([]APIFighter, 0, len(team.Members))
+		for _, member := range team.Members {
+			members = append(members, newAPIFighter(member))
+		}
+		state.Teams = append(state.Teams, APITeam{
+			Members:     members,
+			Placement:   gs.Placement(i),
+			Challenging: gs.Challenging(i),
+		})
+	}
+	if t := gs.Tournament; t != nil {
+		matches := make([]APIMatch, 0, len(t.Matches))
+		for _, match := range t.Matches {
+			matches = append(matches, APIMatch{match.Bracket.String(), match.Round, match.Left, match.Right, match.Winner})
+		}
+		state.Tournament = &APITournament{
+			ID:       t.ID,
+			Round:    t.Round,
+			Entrants: t.Entrants,
+			Matches:  matches,
+			Current:  t.Current,
+			Champion: t.Champion,
+		}
+	}
+	return state
+}
+
+// Number of recent states kept so reconnecting clients can resume the stream without missing a tick
+const STATE_HISTORY = 64
+
+// A state framed as a server-sent event, tagged with an id clients send back as Last-Event-ID when reconnecting
+type stateEvent struct {
+	id    int64
+	frame []byte
+}
+
+// Latest state published by the game loop, served to clients that poll instead of streaming
+var latestState []byte
+var recentStates []stateEvent
+var lastStateID int64
+var latestStateMu sync.RWMutex
+var apiHub *Hub
+
+// Store the state of the latest tick and send it to every client of the JSON stream
+func publishState(gs game.GameState, hub *Hub) {
+	payload, err := json.Marshal(newAPIState(gs))
+	if err != nil {
+		arenaLogger.Error("Unable to encode game state", "err", err)
+		return
+	}
+	latestStateMu.Lock()
+	lastStateID += 1
+	var frame bytes.Buffer
+	WriteSSEEvent(&frame, lastStateID, payload)
+	latestState = payload
+	recentStates = append(recentStates, stateEvent{lastStateID, frame.Bytes()})
+	if len(recentStates) > STATE_HISTORY {
+		recentStates = recentStates[len(recentStates)-STATE_HISTORY:]
+	}
+	latestStateMu.Unlock()
+	if hub.HasClients() {
+		hub.broadcast <- frame.Bytes()
+	}
+}
+
+func currentState() []byte {
+	latestStateMu.RLock()
+	defer latestStateMu.RUnlock()
+	return latestState
+}
+
+// Framed states published after the given id, only the latest one when the id is unknown or too old to replay
+func statesSince(lastID int64) [][]byte {
+	latestStateMu.RLock()
+	defer latestStateMu.RUnlock()
+	if len(recentStates) == 0 {
+		return nil
+	}
+	if lastID == lastStateID {
+		return nil
+	}
+	// New clients only need the latest state, and ids from before a restart of the server may be ahead of the current ones
+	if lastID == 0 || lastID < recentStates[0].id-1 || lastID > lastStateID {
+		return [][]byte{recentStates[len(recentStates)-1].frame}
+	}
+	frames := [][]byte{}
+	for _, event := range recentStates {
+		if event.id > lastID {
+			frames = append(frames, event.frame)
+		}
+	}
+	return frames
+}
+
+type APIError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("Unable to write response", "err", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, APIError{message})
+}
+
+type APILoginRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type APIToken struct {
+	Token            string    `json:"token"` // Sent back in the Authorization header as a bearer token
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken,omitempty"` // Traded for new tokens at /refresh, replaced every time
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt,omitzero"`
+}
+
+func apiToken(tokens sessionTokens) APIToken {
+	return APIToken{tokens.Access, tokens.AccessExpires, tokens.Refresh, tokens.RefreshExpires}
+}
+
+// Log in or sign up like the login form does, returning the token in the body instead of a cookie
+func handleAPILogin(w http.ResponseWriter, r *http.Request) {
+	var request APILoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Name == "" {
+		writeAPIError(w, http.StatusBadRequest, "expected a name and password")
+		return
+	}
+	userID, err := login(r, request.Name, request.Password)
+	var limitErr rateLimitError
+	if errors.As(err, &limitErr) {
+		logins.Inc("limited")
+		writeRateLimited(w, r, limitErr)
+		return
+	} else if err != nil {
+		requestLogger(r).Info("Login failed", "user", request.Name, "err", err)
+		logins.Inc("failure")
+		writeAPIError(w, http.StatusUnauthorized, "unable to log in")
+		return
+	}
+	if banned, _ := db.IsUserBanned(userID); banned {
+		logins.Inc("banned")
+		writeAPIError(w, http.StatusForbidden, errBanned.Error())
+		return
+	}
+	logins.Inc("success")
+	tokens, err := startSession(r, userID, request.Name)
+	if err != nil {
+		requestLogger(r).Error("Unable to start session", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to log in")
+		return
+	}
+	writeJSON(w, http.StatusOK, apiToken(tokens))
+}
+
+func handleAPIState(w http.ResponseWriter, r *http.Request) {
+	state := currentState()
+	if state == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "game has not started yet")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(state)
+}
+
+/*
+Streams the state of every tick as a JSON payload
+
+New clients start with the current state, clients reconnecting with a Last-Event-ID header first receive every
+state they missed when it is still kept in the recent history
+*/
+func handleAPIStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	client := make(chan []byte, 8)
+	apiHub.register <- client
+	defer func() { apiHub.unregister <- client }()
+
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	for _, frame := range statesSince(lastID) {
+		if _, err := w.Write(frame); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case frame, ok := <-client:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(frame); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Bet on any open market, side is used by duel bets, fighter by champion and royale bets and kind by royale bets
+type APIBetRequest struct {
+	Market  string `json:"market"` // One of "duel", "champion" or "royale"
+	Side    string `json:"side,omitempty"`
+	Fighter string `json:"fighter,omitempty"`
+	Kind    string `json:"kind,omitempty"` // "winner" or "top3"
+	Amount  int    `json:"amount"`
+}
+
+func handleAPIBets(w http.ResponseWriter, r *http.Request) {
+	var bet APIBetRequest
+	if err := json.NewDecoder(r.Body).Decode(&bet); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "unable to decode bet")
+		return
+	}
+	userName, _ := r.Context().Value(userNameKey).(string)
+
+	var err error
+	switch bet.Market {
+	case "duel":
+		switch bet.Side {
+		case "left":
+			err = SetBet(userName, bet.Amount, true)
+		case "right":
+			err = SetBet(userName, bet.Amount, false)
+		default:
+			writeAPIError(w, http.StatusBadRequest, "side must be left or right")
+			return
+		}
+	case "champion":
+		err = SetChampionBet(userName, bet.Amount, bet.Fighter)
+	case "royale":
+		kind, ok := parseRoyaleBetKind(bet.Kind)
+		if !ok {
+			writeAPIError(w, http.StatusBadRequest, "kind must be winner or top3")
+			return
+		}
+		err = SetRoyaleBet(userName, bet.Amount, bet.Fighter, kind)
+	default:
+		writeAPIError(w, http.StatusBadRequest, "market must be duel, champion or royale")
+		return
+	}
+	if errors.Is(err, errNotEnoughGold) {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		writeAPIError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, bet)
+}
+
+type APIUser struct {
+	ID   int64           `json:"id"`
+	Name string          `json:"name"`
+	Gold int             `json:"gold"`
+	Bot  bool            `json:"bot"`  // Bots are ranked apart from humans
+	Bets []APIBetRequest `json:"bets"` // Bets still waiting on a result
+}
+
+func handleAPIMe(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(userIDKey).(int64)
+	userName, _ := r.Context().Value(userNameKey).(string)
+	gold, err := db.GetUserGold(userName)
+	if err != nil {
+		requestLogger(r).Error("Unable to get gold", "err", err)
+		writeAPIError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	bot, err := db.IsUserBot(userName)
+	if err != nil {
+		requestLogger(r).Error("Unable to check whether the user is a bot", "err", err)
+	}
+
+	user := APIUser{ID: userID, Name: userName, Gold: gold, Bot: bot, Bets: []APIBetRequest{}}
+	open := BetsOf(userName)
+	if open.Duel != nil {
+		side := "right"
+		if open.Duel.BetSide {
+			side = "left"
+		}
+		user.Bets = append(user.Bets, APIBetRequest{Market: "duel", Side: side, Amount: open.Duel.BetAmount})
+	}
+	if open.Champion != nil {
+		user.Bets = append(user.Bets, APIBetRequest{Market: "champion", Fighter: open.Champion.Fighter, Amount: open.Champion.BetAmount})
+	}
+	if open.Royale != nil {
+		user.Bets = append(user.Bets, APIBetRequest{Market: "royale", Fighter: open.Royale.Fighter, Kind: open.Royale.Kind.String(), Amount: open.Royale.BetAmount})
+	}
+	writeJSON(w, http.StatusOK, user)
+}
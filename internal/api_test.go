@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"js-bet/internal/game"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIState(t *testing.T) {
+	gs := game.NewTeamBattle(2)
+	gs.StepGame()
+	state := newAPIState(gs)
+	if state.Phase != "preround" || !state.BettingOpen {
+		t.Errorf("expected an open pre-round, got phase %q and betting open %t", state.Phase, state.BettingOpen)
+	}
+	if len(state.Teams) != 2 || len(state.Teams[0].Members) != 2 {
+		t.Fatalf("expected two teams of two, got %+v", state.Teams)
+	}
+	if name := state.Teams[0].Members[0].Name; name != gs.Teams[0].Members[0].Name {
+		t.Errorf("expected %s leading the left team, got %s", gs.Teams[0].Members[0].Name, name)
+	}
+}
+
+func postBet(body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, API_PREFIX+"/bets", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), userNameKey, "tester"))
+	w := httptest.NewRecorder()
+	handleAPIBets(w, r)
+	return w
+}
+
+func TestAPIBets(t *testing.T) {
+	useTestDB(t)
+	db.CheckAddUser("tester", "pass")
+	defer ClearBets()
+	CloseBetting()
+	if w := postBet(`{"market":"duel","side":"left","amount":5}`); w.Code != http.StatusConflict {
+		t.Errorf("expected a conflict while betting is closed, got %d", w.Code)
+	}
+
+	OpenBetting(game.New())
+	defer CloseBetting()
+	if w := postBet(`{"market":"horses","amount":5}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown market to be rejected, got %d", w.Code)
+	}
+	if w := postBet(fmt.Sprintf(`{"market":"duel","side":"left","amount":%d}`, DefaultGold+1)); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a bet over the user's gold to be rejected, got %d", w.Code)
+	}
+	w := postBet(`{"market":"duel","side":"right","amount":5}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected the bet to be placed, got %d: %s", w.Code, w.Body)
+	}
+	var placed APIBetRequest
+	if err := json.NewDecoder(w.Body).Decode(&placed); err != nil {
+		t.Fatal(err)
+	}
+	if open := BetsOf("tester"); open.Duel == nil || open.Duel.BetSide || open.Duel.BetAmount != placed.Amount {
+		t.Errorf("expected a bet of %d on the right, got %+v", placed.Amount, open.Duel)
+	}
+}
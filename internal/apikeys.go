@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every API key starts with this prefix so keys can be told apart from login tokens
+const API_KEY_PREFIX = "jsb_"
+
+// Requests per minute allowed for a key unless another limit is asked for when creating it
+const DEFAULT_KEY_RATE_LIMIT = 60
+const MAX_KEY_RATE_LIMIT = 600
+
+type APIKeyScope uint
+
+const (
+	_          = iota
+	SCOPE_READ // 1 Read the account and the game
+	SCOPE_BET  // 2 Read and place bets, the scope of logged in users
+)
+
+func (s APIKeyScope) String() string {
+	switch s {
+	case SCOPE_READ:
+		return "read"
+	case SCOPE_BET:
+		return "bet"
+	}
+	return ""
+}
+
+func parseAPIKeyScope(s string) (APIKeyScope, bool) {
+	switch s {
+	case "read":
+		return SCOPE_READ, true
+	case "bet":
+		return SCOPE_BET, true
+	}
+	return 0, false
+}
+
+/*
+Key created by a user for programs acting on their behalf
+
+Only a hash of the key is stored, the key itself is shown once when it is created and the
+prefix of it is kept so users can tell their keys apart
+*/
+type APIKey struct {
+	ID        int64
+	UserID    int64
+	UserName  string
+	Name      string
+	Prefix    string
+	Scope     APIKeyScope
+	RateLimit int // Requests per minute
+	CreatedAt time.Time
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateAPIKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return API_KEY_PREFIX + hex.EncodeToString(secret), nil
+}
+
+// API keys are sent in the X-API-Key header, or as a bearer token by clients that only set Authorization
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); strings.HasPrefix(bearer, API_KEY_PREFIX) {
+		return bearer
+	}
+	return ""
+}
+
+var keyLimiter = newRateLimiter()
+
+type APIKeyView struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scope     string    `json:"scope"`
+	RateLimit int       `json:"rateLimit"`
+	CreatedAt time.Time `json:"createdAt"`
+	Key       string    `json:"key,omitempty"` // Only sent once, in the response creating the key
+}
+
+func newAPIKeyView(key APIKey) APIKeyView {
+	return APIKeyView{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scope:     key.Scope.String(),
+		RateLimit: key.RateLimit,
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+type APIKeyRequest struct {
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`               // "read" or "bet"
+	RateLimit int    `json:"rateLimit,omitempty"` // Requests per minute
+}
+
+/*
+Create and list the keys of the logged in user
+
+Accounts holding a key with the betting scope are marked as bots, so they are ranked apart from humans, until
+their last betting key is revoked
+*/
+func handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(userIDKey).(int64)
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := db.ListAPIKeys(userID)
+		if err != nil {
+			requestLogger(r).Error("Unable to list api keys", "err", err)
+			writeAPIError(w, http.StatusInternalServerError, "unable to list keys")
+			return
+		}
+		views := make([]APIKeyView, 0, len(keys))
+		for _, key := range keys {
+			views = append(views, newAPIKeyView(key))
+		}
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		var request APIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Name == "" {
+			writeAPIError(w, http.StatusBadRequest, "expected a name and scope")
+			return
+		}
+		scope, ok := parseAPIKeyScope(request.Scope)
+		if !ok {
+			writeAPIError(w, http.StatusBadRequest, "scope must be read or bet")
+			return
+		}
+		if request.RateLimit == 0 {
+			request.RateLimit = DEFAULT_KEY_RATE_LIMIT
+		}
+		if request.RateLimit < 0 || request.RateLimit > MAX_KEY_RATE_LIMIT {
+			writeAPIError(w, http.StatusBadRequest, "rate limit must be between 1 and "+strconv.Itoa(MAX_KEY_RATE_LIMIT))
+			return
+		}
+
+		secret, err := generateAPIKey()
+		if err != nil {
+			requestLogger(r).Error("Unable to generate api key", "err", err)
+			writeAPIError(w, http.StatusInternalServerError, "unable to create key")
+			return
+		}
+		key := APIKey{
+			UserID:    userID,
+			Name:      request.Name,
+			Prefix:    secret[:len(API_KEY_PREFIX)+8],
+			Scope:     scope,
+			RateLimit: request.RateLimit,
+			CreatedAt: time.Now(),
+		}
+		if err = db.CreateAPIKey(&key, hashAPIKey(secret)); err != nil {
+			requestLogger(r).Error("Unable to store api key", "err", err)
+			writeAPIError(w, http.StatusInternalServerError, "unable to create key")
+			return
+		}
+		if scope == SCOPE_BET {
+			if err = db.SetUserBot(userID, true); err != nil {
+				requestLogger(r).Error("Unable to mark user as a bot", "err", err)
+			}
+		}
+		view := newAPIKeyView(key)
+		view.Key = secret
+		writeJSON(w, http.StatusCreated, view)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "expected GET or POST")
+	}
+}
+
+/*
+Revoke one of the logged in user's keys, served under /api/v1/keys/{id}
+
+Revoking the last key with the betting scope ranks the account with humans again
+*/
+func handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "expected a key id")
+		return
+	}
+	userID, _ := r.Context().Value(userIDKey).(int64)
+	revoked, err := db.RevokeAPIKey(userID, keyID)
+	if err != nil {
+		requestLogger(r).Error("Unable to revoke api key", "api_key_id", keyID, "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to revoke key")
+		return
+	}
+	if !revoked {
+		writeAPIError(w, http.StatusNotFound, "key not found")
+		return
+	}
+	if err = unmarkBotWithoutBetKeys(userID); err != nil {
+		requestLogger(r).Error("Unable to unmark user as a bot", "err", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Richest accounts, humans unless ?accounts=bots is asked for
+func handleAPILeaderboard(w http.ResponseWriter, r *http.Request) {
+	bots := false
+	switch r.URL.Query().Get("accounts") {
+	case "", "humans":
+	case "bots":
+		bots = true
+	default:
+		writeAPIError(w, http.StatusBadRequest, "accounts must be humans or bots")
+		return
+	}
+	entries, err := db.Leaderboard(bots, 20)
+	if err != nil {
+		requestLogger(r).Error("Unable to load leaderboard", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to load leaderboard")
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func unmarkBotWithoutBetKeys(userID int64) error {
+	keys, err := db.ListAPIKeys(userID)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key.Scope == SCOPE_BET {
+			return nil
+		}
+	}
+	return db.SetUserBot(userID, false)
+}
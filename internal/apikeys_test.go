@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Point the package database at a fresh in-memory database for the length of a test
+func useTestDB(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1) // Every connection to :memory: opens its own database
+	previous := db
+	db = DBClient{conn: conn}
+	t.Cleanup(func() {
+		conn.Close()
+		db = previous
+	})
+	if err = db.InitDB(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter()
+	limiter.now = func() time.Time { return now }
+	for i := range 3 {
+		if !limiter.allow("key", 3) {
+			t.Fatalf("request %d should be within the limit", i+1)
+		}
+	}
+	if limiter.allow("key", 3) {
+		t.Error("fourth request in a minute should be limited")
+	}
+	if !limiter.allow("other", 3) {
+		t.Error("keys should not share a bucket")
+	}
+	now = now.Add(20 * time.Second)
+	if !limiter.allow("key", 3) {
+		t.Error("a request should be allowed again after a third of a minute")
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	useTestDB(t)
+	// Key ids start over with every test database, so buckets left by an earlier run would limit the new keys
+	previousLimiter := keyLimiter
+	keyLimiter = newRateLimiter()
+	t.Cleanup(func() { keyLimiter = previousLimiter })
+	userID, err := db.CheckAddUser("botter", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loggedIn := func(r *http.Request) *http.Request {
+		return withUserClaims(r, &UserClaims{UserID: userID, UserName: "botter"})
+	}
+	keys := authMiddlewareStrict(requireLogin(http.HandlerFunc(handleAPIKeys)))
+	me := authMiddlewareStrict(requireScope(SCOPE_READ, http.HandlerFunc(handleAPIMe)))
+	bets := authMiddlewareStrict(requireScope(SCOPE_BET, http.HandlerFunc(handleAPIBets)))
+	placeBet := authMiddlewareStrict(requireScope(SCOPE_BET, errorHandler(handlePlaceBet)))
+
+	createKey := func(scope string, rateLimit int) APIKeyView {
+		body := fmt.Sprintf(`{"name":"%s key","scope":"%s","rateLimit":%d}`, scope, scope, rateLimit)
+		r := httptest.NewRequest(http.MethodPost, API_PREFIX+"/keys", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handleAPIKeys(w, loggedIn(r))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected the key to be created, got %d: %s", w.Code, w.Body)
+		}
+		var view APIKeyView
+		if err := json.NewDecoder(w.Body).Decode(&view); err != nil {
+			t.Fatal(err)
+		}
+		return view
+	}
+	serve := func(handler http.Handler, method string, path string, key string, body string) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	readKey := createKey("read", 4)
+	if code := serve(me, http.MethodGet, API_PREFIX+"/me", readKey.Key, ""); code != http.StatusOK {
+		t.Errorf("read key should see the account, got %d", code)
+	}
+	if code := serve(bets, http.MethodPost, API_PREFIX+"/bets", readKey.Key, `{"market":"duel","side":"left","amount":1}`); code != http.StatusForbidden {
+		t.Errorf("read key should not place bets, got %d", code)
+	}
+	if code := serve(placeBet, http.MethodPost, "/user/placeBet", readKey.Key, "amount=1&side=left"); code != http.StatusForbidden {
+		t.Errorf("read key should not place bets through the site either, got %d", code)
+	}
+	if code := serve(keys, http.MethodGet, API_PREFIX+"/keys", readKey.Key, ""); code != http.StatusForbidden {
+		t.Errorf("keys should not manage keys, got %d", code)
+	}
+	if code := serve(me, http.MethodGet, API_PREFIX+"/me", readKey.Key, ""); code != http.StatusTooManyRequests {
+		t.Errorf("fifth request of a key limited to four a minute should be rejected, got %d", code)
+	}
+	if bot, _ := db.IsUserBot("botter"); bot {
+		t.Error("read-only keys should not mark the account as a bot")
+	}
+
+	betKey := createKey("bet", 0)
+	if bot, _ := db.IsUserBot("botter"); !bot {
+		t.Error("betting keys should mark the account as a bot")
+	}
+	listed, err := db.ListAPIKeys(userID)
+	if err != nil || len(listed) != 2 || listed[1].Prefix != betKey.Prefix {
+		t.Errorf("expected both keys to be listed, got %+v (%v)", listed, err)
+	}
+
+	r := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("%s/keys/%d", API_PREFIX, betKey.ID), nil)
+	r.SetPathValue("id", strconv.FormatInt(betKey.ID, 10))
+	w := httptest.NewRecorder()
+	handleAPIKey(w, r.WithContext(context.WithValue(r.Context(), userIDKey, userID)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected the key to be revoked, got %d", w.Code)
+	}
+	if code := serve(me, http.MethodGet, API_PREFIX+"/me", betKey.Key, ""); code != http.StatusUnauthorized {
+		t.Errorf("revoked key should be rejected, got %d", code)
+	}
+	if bot, _ := db.IsUserBot("botter"); bot {
+		t.Error("revoking the last betting key should no longer mark the account as a bot")
+	}
+}
@@ -1,40 +1,51 @@
 package assets
 
 import (
-	"fmt"
-	"log"
-	"os"
+	"io/fs"
+	"log/slog"
+	"path"
 	"strings"
 )
 
 type Assets struct {
-	IconsSvgs map[string]string
+	IconsSvgs    map[string]string
+	Cues         ResolvedCues
+	Fingerprints map[string]string // Content hashes of static files keyed by their url path
 }
 
 func New() Assets {
 	newAssets := Assets{
-		IconsSvgs: make(map[string]string),
+		IconsSvgs:    make(map[string]string),
+		Fingerprints: make(map[string]string),
 	}
 	return newAssets
 }
 
-func (a *Assets) ReadIcons(iconsDirPath string) {
-	icons, err := os.ReadDir(iconsDirPath)
+// Read and sanitize every svg icon in the given directory of the static files, skipping icons that are not valid
+func (a *Assets) ReadIcons(fsys fs.FS, iconsDir string) error {
+	icons, err := fs.ReadDir(fsys, iconsDir)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	for i := range len(icons) {
-		icon := icons[i]
+	for _, icon := range icons {
 		name := icon.Name()
+		if !strings.HasSuffix(name, ".svg") {
+			continue
+		}
 		cleanName := strings.TrimSuffix(name, ".svg")
-		path := fmt.Sprintf("./static/icons/%s", name)
-		bytes, err := os.ReadFile(path)
+		bytes, err := fs.ReadFile(fsys, path.Join(iconsDir, name))
+		if err != nil {
+			return err
+		}
+		svg, err := SanitizeSVG(bytes)
 		if err != nil {
-			log.Panic(err)
+			slog.Warn("Skipping icon", "icon", name, "err", err)
+			continue
 		}
-		a.IconsSvgs[cleanName] = string(bytes)
+		a.IconsSvgs[cleanName] = svg
 	}
+	return nil
 }
 
 func (a Assets) GetIcon(name string) string {
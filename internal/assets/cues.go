@@ -0,0 +1,126 @@
+package assets
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// Sound and animation class played when a cue fires, sounds are paths relative to the static directory
+type Cue struct {
+	Sound     string `json:"sound,omitempty"`
+	Animation string `json:"animation,omitempty"`
+}
+
+// Hand authored manifest read from cues.json at the root of the static files
+type CueManifest struct {
+	Fallback  Cue               `json:"fallback"`  // Used for any ability without its own sound or animation
+	Sounds    map[string]string `json:"sounds"`    // Generic sounds such as attack or crit
+	Abilities map[string]Cue    `json:"abilities"` // Keyed by ability cue
+}
+
+/*
+Manifest after checking every asset exists, served to the client preloader and used when rendering
+
+Sounds are keyed by the names sent in data-audios, and animations by the fighter's animation
+*/
+type ResolvedCues struct {
+	Sounds     map[string]string `json:"sounds"`
+	Animations map[string]string `json:"animations"`
+}
+
+/*
+Read the cue manifest and resolve a sound and animation for every ability cue
+
+Abilities missing from the manifest, or whose sound file or animation class cannot be found, fall back
+to the manifest's fallback cue
+*/
+func (a *Assets) LoadCues(fsys fs.FS, abilityCues []string) {
+	manifest := CueManifest{}
+	data, err := fs.ReadFile(fsys, "cues.json")
+	if err != nil {
+		slog.Warn("Unable to read cue manifest, using fallbacks only", "err", err)
+	} else if err = json.Unmarshal(data, &manifest); err != nil {
+		slog.Warn("Unable to parse cue manifest, using fallbacks only", "err", err)
+	}
+	styles := readStyles(fsys, "styles")
+
+	resolved := ResolvedCues{
+		Sounds:     make(map[string]string),
+		Animations: make(map[string]string),
+	}
+	for name, sound := range manifest.Sounds {
+		if soundExists(fsys, sound) {
+			resolved.Sounds[name] = a.URL("/" + sound)
+		} else {
+			slog.Warn("Sound for cue not found", "sound", sound, "cue", name)
+		}
+	}
+	for _, cue := range abilityCues {
+		entry := manifest.Abilities[cue]
+		sound := entry.Sound
+		if sound != "" && !soundExists(fsys, sound) {
+			slog.Warn("Sound for ability not found, using fallback", "sound", sound, "cue", cue)
+			sound = ""
+		}
+		if sound == "" {
+			sound = manifest.Fallback.Sound
+		}
+		if soundExists(fsys, sound) {
+			resolved.Sounds["ability-"+cue] = a.URL("/" + sound)
+		}
+
+		animation := entry.Animation
+		if animation != "" && !strings.Contains(styles, ".animate-"+animation+"-left") {
+			slog.Warn("Animation for ability not found, using fallback", "animation", animation, "cue", cue)
+			animation = ""
+		}
+		if animation == "" {
+			animation = manifest.Fallback.Animation
+		}
+		resolved.Animations["ability-"+cue] = animation
+	}
+	a.Cues = resolved
+}
+
+func soundExists(fsys fs.FS, sound string) bool {
+	if sound == "" {
+		return false
+	}
+	info, err := fs.Stat(fsys, sound)
+	return err == nil && info.Size() > 0
+}
+
+// Contents of every stylesheet, used to check animation classes are defined
+func readStyles(fsys fs.FS, stylesDir string) string {
+	files, err := fs.ReadDir(fsys, stylesDir)
+	if err != nil {
+		slog.Warn("Unable to read styles", "err", err)
+		return ""
+	}
+	var builder strings.Builder
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".css") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(stylesDir, file.Name()))
+		if err != nil {
+			continue
+		}
+		builder.Write(data)
+	}
+	return builder.String()
+}
+
+// Animation class for a fighter's current animation, ability animations are looked up in the manifest
+func (c ResolvedCues) Animation(fighterAnim string) string {
+	if animation, ok := c.Animations[fighterAnim]; ok {
+		return animation
+	}
+	if strings.HasPrefix(fighterAnim, "ability") {
+		return "ability"
+	}
+	return fighterAnim
+}
@@ -0,0 +1,48 @@
+package assets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, data string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadCuesFallsBackOnMissingAssets(t *testing.T) {
+	staticPath := t.TempDir()
+	writeFile(t, filepath.Join(staticPath, "audio", "generic.wav"), "RIFF")
+	writeFile(t, filepath.Join(staticPath, "audio", "heal.wav"), "RIFF")
+	writeFile(t, filepath.Join(staticPath, "styles", "animations.css"), ".animate-ability-heal-left {}")
+	writeFile(t, filepath.Join(staticPath, "cues.json"), `{
+		"fallback": {"sound": "audio/generic.wav", "animation": "ability"},
+		"abilities": {
+			"heal": {"sound": "audio/heal.wav", "animation": "ability-heal"},
+			"broken": {"sound": "audio/missing.wav", "animation": "ability-missing"}
+		}
+	}`)
+
+	a := New()
+	a.LoadCues(os.DirFS(staticPath), []string{"heal", "broken", "unlisted"})
+
+	expected := map[string]Cue{
+		"heal":     {Sound: "/audio/heal.wav", Animation: "ability-heal"},
+		"broken":   {Sound: "/audio/generic.wav", Animation: "ability"},
+		"unlisted": {Sound: "/audio/generic.wav", Animation: "ability"},
+	}
+	for cue, want := range expected {
+		got := Cue{Sound: a.Cues.Sounds["ability-"+cue], Animation: a.Cues.Animation("ability-" + cue)}
+		if got != want {
+			t.Errorf("cue %s resolved to %+v, expected %+v", cue, got, want)
+		}
+	}
+	if a.Cues.Animation("attack") != "attack" {
+		t.Errorf("non ability animations should pass through unchanged")
+	}
+}
@@ -0,0 +1,56 @@
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"slices"
+)
+
+// Extensions of the files served under fingerprinted urls, which browsers may cache for as long as they like
+var fingerprintedExtensions = []string{".css", ".js", ".wav"}
+
+/*
+Hash the contents of every stylesheet, script and sound so their urls change whenever the files do
+
+Fingerprints are keyed by the url path of the file, such as /styles/index.css
+*/
+func (a *Assets) Fingerprint(fsys fs.FS) error {
+	a.Fingerprints = make(map[string]string)
+	return fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !slices.Contains(fingerprintedExtensions, path.Ext(name)) {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		a.Fingerprints["/"+name] = hex.EncodeToString(sum[:6])
+		return nil
+	})
+}
+
+// Url of a static file carrying its fingerprint, the plain path for files without one
+func (a Assets) URL(urlPath string) string {
+	if version, ok := a.Fingerprints[urlPath]; ok {
+		return urlPath + "?v=" + version
+	}
+	return urlPath
+}
+
+// Whether a request for the file asked for its current contents, so the response never changes
+func (a Assets) IsCurrent(urlPath string, version string) bool {
+	current, ok := a.Fingerprints[urlPath]
+	return ok && version == current
+}
+
+// Replace every quoted url of a fingerprinted file in a page with its fingerprinted url
+func (a Assets) RewriteURLs(page []byte) []byte {
+	for urlPath := range a.Fingerprints {
+		page = bytes.ReplaceAll(page, []byte(`"`+urlPath+`"`), []byte(`"`+a.URL(urlPath)+`"`))
+	}
+	return page
+}
@@ -0,0 +1,44 @@
+package assets
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFingerprint(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":       {Data: []byte(`<link href="/styles/index.css"><script src="/js/app.js"></script><img src="/icons/React.svg">`)},
+		"styles/index.css": {Data: []byte("body {}")},
+		"js/app.js":        {Data: []byte("console.log('v1')")},
+		"icons/React.svg":  {Data: []byte("<svg></svg>")},
+		"audio/attack.wav": {Data: []byte("RIFF")},
+		"cues.json":        {Data: []byte(`{"sounds": {"attack": "audio/attack.wav"}}`)},
+	}
+	a := New()
+	if err := a.Fingerprint(fsys); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Fingerprints["/icons/React.svg"]; ok {
+		t.Error("icons are not fingerprinted")
+	}
+	style := a.URL("/styles/index.css")
+	if !strings.HasPrefix(style, "/styles/index.css?v=") || !a.IsCurrent("/styles/index.css", strings.TrimPrefix(style, "/styles/index.css?v=")) {
+		t.Errorf("expected a fingerprinted url, got %s", style)
+	}
+	page := string(a.RewriteURLs(fsys["index.html"].Data))
+	if !strings.Contains(page, `"`+style+`"`) || !strings.Contains(page, `"/icons/React.svg"`) {
+		t.Errorf("expected only fingerprinted files to be rewritten, got %s", page)
+	}
+	a.LoadCues(fsys, nil)
+	if a.Cues.Sounds["attack"] != a.URL("/audio/attack.wav") {
+		t.Errorf("expected cue sounds to use fingerprinted urls, got %s", a.Cues.Sounds["attack"])
+	}
+
+	previous := style
+	fsys["styles/index.css"] = &fstest.MapFile{Data: []byte("body { margin: 0 }")}
+	a.Fingerprint(fsys)
+	if a.URL("/styles/index.css") == previous || a.IsCurrent("/styles/index.css", strings.TrimPrefix(previous, "/styles/index.css?v=")) {
+		t.Error("expected the url to change with the contents of the file")
+	}
+}
@@ -0,0 +1,217 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+/*
+Elements kept in icons, compared in lowercase. Anything else is dropped along with everything inside it, which
+covers scripts, stylesheets, animations that could rewrite attributes and elements embedding arbitrary html
+*/
+var svgElements = []string{
+	"svg", "g", "defs", "title", "desc", "symbol", "use",
+	"path", "circle", "ellipse", "line", "polyline", "polygon", "rect", "text", "tspan",
+	"lineargradient", "radialgradient", "stop", "clippath", "mask",
+}
+
+// Attributes kept on icon elements, compared in lowercase, href is checked separately
+var svgAttributes = []string{
+	"xmlns", "version", "viewbox", "preserveaspectratio", "id", "role", "aria-label", "aria-hidden",
+	"d", "cx", "cy", "r", "rx", "ry", "x", "y", "x1", "y1", "x2", "y2", "dx", "dy", "width", "height", "points",
+	"transform", "pathlength", "fill", "fill-rule", "fill-opacity", "clip-rule", "clip-path", "mask", "opacity",
+	"stroke", "stroke-width", "stroke-linecap", "stroke-linejoin", "stroke-miterlimit", "stroke-dasharray",
+	"stroke-dashoffset", "stroke-opacity", "offset", "stop-color", "stop-opacity", "gradientunits",
+	"gradienttransform", "spreadmethod", "fx", "fy", "clippathunits", "maskunits", "maskcontentunits",
+	"font-family", "font-size", "font-weight", "text-anchor", "dominant-baseline", "letter-spacing",
+}
+
+// Attributes on the root element replaced by its viewBox, so the stylesheets alone decide how large icons are
+var svgSizeAttributes = []string{"width", "height"}
+
+var svgLength = regexp.MustCompile(`^\s*([0-9.]+)\s*(px)?\s*$`)
+
+// Colors allowed in placeholder badges, anything else falls back to grey
+var badgeColor = regexp.MustCompile(`^#[0-9a-fA-F]{3,8}$`)
+
+func qualifiedName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// Links are only kept when they point inside the icon, such as a gradient or a shape reused by another element
+func isLocalHref(attr xml.Attr) bool {
+	return strings.HasPrefix(strings.TrimSpace(attr.Value), "#")
+}
+
+func isHref(attr xml.Attr) bool {
+	space := strings.ToLower(attr.Name.Space)
+	return strings.ToLower(attr.Name.Local) == "href" && (space == "" || space == "xlink")
+}
+
+func isAllowedAttribute(attr xml.Attr) bool {
+	if strings.ToLower(attr.Name.Space) == "xmlns" {
+		return true // Namespace declarations such as xmlns:xlink
+	}
+	if isHref(attr) {
+		return isLocalHref(attr)
+	}
+	return attr.Name.Space == "" && slices.Contains(svgAttributes, strings.ToLower(attr.Name.Local))
+}
+
+// Whether an element is dropped along with its contents, use elements pointing outside the icon are dropped too
+func isUnsafeElement(element xml.StartElement) bool {
+	name := strings.ToLower(qualifiedName(element.Name))
+	if !slices.Contains(svgElements, name) {
+		return true
+	}
+	if name == "use" {
+		for _, attr := range element.Attr {
+			if isHref(attr) && !isLocalHref(attr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Drop the width and height of the root element, deriving a viewBox from them when it has none
+func normalizeSvgSize(attrs []xml.Attr) ([]xml.Attr, error) {
+	var width, height string
+	hasViewBox := false
+	kept := []xml.Attr{}
+	for _, attr := range attrs {
+		name := strings.ToLower(attr.Name.Local)
+		switch {
+		case name == "viewbox":
+			hasViewBox = true
+		case name == "width":
+			width = attr.Value
+		case name == "height":
+			height = attr.Value
+		}
+		if !slices.Contains(svgSizeAttributes, name) {
+			kept = append(kept, attr)
+		}
+	}
+	if hasViewBox {
+		return kept, nil
+	}
+	w, h := svgLength.FindStringSubmatch(width), svgLength.FindStringSubmatch(height)
+	if w == nil || h == nil {
+		return nil, fmt.Errorf("error: svg has neither a viewBox nor a size in pixels")
+	}
+	return append(kept, xml.Attr{Name: xml.Name{Local: "viewBox"}, Value: fmt.Sprintf("0 0 %s %s", w[1], h[1])}), nil
+}
+
+/*
+Check an icon is a well formed svg and make it safe to inline into the page
+
+Only drawing elements and their presentation attributes are kept, so prologs, doctypes, comments, scripts,
+styles, animations, event handlers and links leading outside the icon are all removed. The root element is sized
+by its viewBox only
+*/
+func SanitizeSVG(data []byte) (string, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return "", fmt.Errorf("error: svg is empty")
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var out strings.Builder
+	open := []string{}
+	skipping := 0 // Depth inside an unsafe element, whose contents are all dropped
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("error: invalid svg: %w", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			name := qualifiedName(token.Name)
+			if len(open) == 0 && (strings.ToLower(name) != "svg" || out.Len() > 0) {
+				return "", fmt.Errorf("error: expected a single svg root element, found %s", name)
+			}
+			open = append(open, name)
+			if skipping > 0 || isUnsafeElement(token) {
+				skipping += 1
+				continue
+			}
+			attrs := token.Attr
+			if len(open) == 1 {
+				if attrs, err = normalizeSvgSize(attrs); err != nil {
+					return "", err
+				}
+			}
+			out.WriteString("<" + name)
+			for _, attr := range attrs {
+				if !isAllowedAttribute(attr) {
+					continue
+				}
+				out.WriteString(" " + qualifiedName(attr.Name) + `="` + html.EscapeString(attr.Value) + `"`)
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			name := qualifiedName(token.Name)
+			if len(open) == 0 || open[len(open)-1] != name {
+				return "", fmt.Errorf("error: invalid svg: unexpected closing %s", name)
+			}
+			open = open[:len(open)-1]
+			if skipping > 0 {
+				skipping -= 1
+				continue
+			}
+			out.WriteString("</" + name + ">")
+		case xml.CharData:
+			if skipping == 0 && len(open) > 0 {
+				xml.EscapeText(&out, token)
+			}
+		}
+	}
+	if len(open) > 0 {
+		return "", fmt.Errorf("error: invalid svg: %s is never closed", open[len(open)-1])
+	} else if out.Len() == 0 {
+		return "", fmt.Errorf("error: expected a single svg root element")
+	}
+	return out.String(), nil
+}
+
+// Round badge with the initial of a fighter, shown for fighters without a usable icon
+func PlaceholderIcon(name string, color string) string {
+	if !badgeColor.MatchString(color) {
+		color = "#808080"
+	}
+	initial := "?"
+	if name != "" {
+		initial = strings.ToUpper(string([]rune(name)[0]))
+	}
+	return `<svg viewBox="0 0 32 32" xmlns="http://www.w3.org/2000/svg" role="img" aria-label="` + html.EscapeString(name) + `">` +
+		`<circle cx="16" cy="16" r="14" fill="` + color + `"></circle>` +
+		`<text x="16" y="21" text-anchor="middle" font-size="14" font-family="sans-serif" fill="#ffffff">` + html.EscapeString(initial) + `</text>` +
+		`</svg>`
+}
+
+/*
+Give every fighter without an icon a placeholder badge in their color, colors are keyed by fighter name
+
+Returns the names of the fighters that were missing an icon
+*/
+func (a *Assets) FillMissingIcons(colors map[string]string) []string {
+	missing := []string{}
+	for name, color := range colors {
+		if _, ok := a.IconsSvgs[name]; !ok {
+			a.IconsSvgs[name] = PlaceholderIcon(name, color)
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
@@ -0,0 +1,80 @@
+package assets
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	svg, err := SanitizeSVG([]byte(`<?xml version="1.0" encoding="utf-8"?><!-- Uploaded to: SVG Repo -->
+<!DOCTYPE svg>
+<svg width="800px" height="800px" viewBox="0 0 32 32" onload="alert(1)" xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">
+<script>alert(2)</script>
+<a xlink:href="javascript:alert(3)"><rect width="32" height="32"/></a>
+<circle cx="16" cy="16" r="14" fill="#0769AD" onclick="alert(4)" style="display:none"/>
+<use xlink:href="data:image/svg+xml;base64,PHN2Zz48L3N2Zz4="/><use href="#shape"/>
+<foreignObject><div>hidden</div></foreignObject>
+<text>1 &lt; 2</text>
+</svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unwanted := range []string{"<?xml", "<!--", "DOCTYPE", "alert", "<script", "foreignObject", "hidden", `width="`, "800px", "<rect", "style", "data:"} {
+		if strings.Contains(svg, unwanted) {
+			t.Errorf("expected %q to be stripped from %s", unwanted, svg)
+		}
+	}
+	for _, wanted := range []string{`<svg viewBox="0 0 32 32"`, `xmlns:xlink="http://www.w3.org/1999/xlink"`, `<circle cx="16" cy="16" r="14" fill="#0769AD"></circle>`, `<use href="#shape"></use>`, "1 &lt; 2"} {
+		if !strings.Contains(svg, wanted) {
+			t.Errorf("expected %q to be kept in %s", wanted, svg)
+		}
+	}
+
+	for _, unsafe := range []string{
+		`<svg viewBox="0 0 1 1"><SCRIPT>alert(1)</SCRIPT></svg>`,
+		`<svg viewBox="0 0 1 1"><a><set attributeName="href" to="javascript:alert(1)"/></a></svg>`,
+		`<svg viewBox="0 0 1 1"><style>body{display:none}</style></svg>`,
+		`<svg viewBox="0 0 1 1"><path d="M0 0"><animate attributeName="d" to="M1 1"/></path></svg>`,
+	} {
+		svg, err := SanitizeSVG([]byte(unsafe))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, unwanted := range []string{"alert", "javascript", "<set", "<style", "display", "<animate"} {
+			if strings.Contains(strings.ToLower(svg), unwanted) {
+				t.Errorf("expected %q to be stripped from %s", unwanted, svg)
+			}
+		}
+	}
+
+	if svg, err := SanitizeSVG([]byte(`<svg width="200" height="100"><path d="M0 0"/></svg>`)); err != nil || !strings.HasPrefix(svg, `<svg viewBox="0 0 200 100">`) {
+		t.Errorf("expected a viewBox derived from the size, got %s (%v)", svg, err)
+	}
+	for _, invalid := range []string{"", "  \n", "<html></html>", "<svg viewBox='0 0 1 1'><path></svg>", "<svg><path/></svg>", "<svg viewBox='0 0 1 1'></svg><svg viewBox='0 0 1 1'></svg>"} {
+		if _, err := SanitizeSVG([]byte(invalid)); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestMissingIconsGetPlaceholders(t *testing.T) {
+	fsys := fstest.MapFS{
+		"icons/React.svg": {Data: []byte(`<svg viewBox="0 0 32 32"><circle r="14"/></svg>`)},
+		"icons/Solid.svg": {Data: []byte{}},
+	}
+	a := New()
+	if err := a.ReadIcons(fsys, "icons"); err != nil {
+		t.Fatal(err)
+	}
+	missing := a.FillMissingIcons(map[string]string{"React": "#58C4DC", "Solid": "#3E5E88", "Qwik": "red;<script>"})
+	if len(missing) != 2 || missing[0] != "Qwik" || missing[1] != "Solid" {
+		t.Errorf("expected Qwik and Solid to be reported, got %v", missing)
+	}
+	if !strings.Contains(a.GetIcon("Solid"), `fill="#3E5E88"`) || !strings.Contains(a.GetIcon("Solid"), ">S</text>") {
+		t.Errorf("expected a badge in Solid's color, got %s", a.GetIcon("Solid"))
+	}
+	if strings.Contains(a.GetIcon("Qwik"), "script") {
+		t.Errorf("expected an invalid color to be replaced, got %s", a.GetIcon("Qwik"))
+	}
+}
@@ -2,80 +2,272 @@ package internal
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// How long an access token stays valid, sessions outlive it by renewing it with their refresh token
+const ACCESS_TOKEN_LIFETIME = 15 * time.Minute
+
+const ACCESS_COOKIE = "jwt_token"
+
 const userIDKey string = "userID"
+const userNameKey string = "userName"
+const scopeKey string = "scope"
+const apiKeyIDKey string = "apiKeyID"
+const sessionIDKey string = "sessionID"
 
 type UserClaims struct {
-	UserID   int64  `json:"user"`
-	Password string `json:"pass"`
+	UserID    int64  `json:"user"`
+	UserName  string `json:"name"`
+	SessionID string `json:"sid"` // Checked on every request so revoking the session logs the token out
 	jwt.RegisteredClaims
 }
 
-// Passes on userID
-func authMiddlewarePermissive(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Checking for JWT in header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			next.ServeHTTP(w, r) // Pass along to next handler
-			// http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
+// Environment variable holding the signing keys, kept out of the flags so they do not show up in process lists
+const SIGNING_KEYS_ENV = "JSBET_SIGNING_KEYS"
+
+// Key tokens are signed with, the id is sent in the kid header of every token
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// The first key signs new tokens, the others are still accepted so tokens signed before a rotation keep working
+var signingKeys = []SigningKey{{"dev", []byte(SECRET)}}
+
+/*
+Read signing keys written as comma separated id:secret pairs, newest first
+
+Keys are rotated by putting a new key in front and dropping the old one once the tokens it signed have expired,
+which takes ACCESS_TOKEN_LIFETIME as refresh tokens are not signed
+*/
+func ParseSigningKeys(s string) ([]SigningKey, error) {
+	keys := []SigningKey{}
+	seen := map[string]bool{}
+	for _, pair := range strings.Split(s, ",") {
+		id, secret, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found || id == "" || secret == "" {
+			return nil, fmt.Errorf("error: signing keys should be written as id:secret pairs, got '%s'", pair)
+		} else if len(secret) < 32 {
+			return nil, fmt.Errorf("error: signing key '%s' is too short, use at least 32 characters", id)
+		} else if seen[id] {
+			return nil, fmt.Errorf("error: signing key '%s' is listed twice", id)
 		}
+		seen[id] = true
+		keys = append(keys, SigningKey{id, []byte(secret)})
+	}
+	return keys, nil
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (any, error) {
-			return []byte(SECRET), nil
-		})
+func findSigningKey(id string) (SigningKey, bool) {
+	for _, key := range signingKeys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
 
-		if err != nil || !token.Valid {
-			next.ServeHTTP(w, r) // Pass along to next handler
-			// http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+// Sign a short lived token for a session, returning it along with the time it expires
+func issueAccessToken(userID int64, userName string, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expires := now.Add(ACCESS_TOKEN_LIFETIME)
+	claims := UserClaims{
+		UserID:    userID,
+		UserName:  userName,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expires),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "js.bet",
+			Subject:   fmt.Sprintf("%d", userID),
+		},
+	}
+	key := signingKeys[0]
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.Secret)
+	return signed, expires, err
+}
+
+// Check the signature and expiry of an access token, without checking its session is still active
+func parseAccessToken(tokenString string) (*UserClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (any, error) {
+		id, _ := token.Header["kid"].(string)
+		key, found := findSigningKey(id)
+		if !found {
+			return nil, errUnknownKey
 		}
+		return key.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, errInvalidToken
+	}
+	claims, ok := token.Claims.(*UserClaims)
+	if !ok || claims.SessionID == "" {
+		return nil, errInvalidClaims
+	}
+	return claims, nil
+}
 
-		claims, ok := token.Claims.(*UserClaims)
-		if !ok {
-			next.ServeHTTP(w, r) // Pass along to next handler
-			// http.Error(w, "Invalid claims", http.StatusUnauthorized)
-			return
+// Browsers send the token as a cookie set on login, other clients may use the Authorization header instead
+func tokenFromRequest(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	cookie, err := r.Cookie(ACCESS_COOKIE)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func withUserClaims(r *http.Request, claims *UserClaims) *http.Request {
+	ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, userNameKey, claims.UserName)
+	ctx = withLogger(ctx, requestLogger(r).With("user_id", claims.UserID))
+	ctx = context.WithValue(ctx, scopeKey, APIKeyScope(SCOPE_BET))
+	ctx = context.WithValue(ctx, sessionIDKey, claims.SessionID)
+	return r.WithContext(ctx)
+}
+
+func withAPIKey(r *http.Request, key APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), userIDKey, key.UserID)
+	ctx = context.WithValue(ctx, userNameKey, key.UserName)
+	ctx = withLogger(ctx, requestLogger(r).With("user_id", key.UserID, "api_key_id", key.ID))
+	ctx = context.WithValue(ctx, scopeKey, key.Scope)
+	ctx = context.WithValue(ctx, apiKeyIDKey, key.ID)
+	return r.WithContext(ctx)
+}
+
+var errUnauthorized = errors.New("Unauthorized")
+var errInvalidToken = errors.New("Invalid token")
+var errInvalidClaims = errors.New("Invalid claims")
+var errUnknownKey = errors.New("Token signed with an unknown key")
+var errSessionEnded = errors.New("Session has ended, log in again")
+var errInvalidKey = errors.New("Invalid API key")
+var errRateLimited = errors.New("Rate limit exceeded")
+var errBanned = errors.New("Account is banned")
+var errWrongPassword = errors.New("Wrong password")
+
+// Find the user making the request, turning away banned users even when their token or key is still valid
+func authenticate(r *http.Request) (*http.Request, error) {
+	authorized, err := identify(r)
+	if err != nil {
+		return r, err
+	}
+	userID, _ := authorized.Context().Value(userIDKey).(int64)
+	banned, err := db.IsUserBanned(userID)
+	if err != nil {
+		return r, errUnauthorized
+	} else if banned {
+		return r, errBanned
+	}
+	return authorized, nil
+}
+
+// Find the user making the request from their API key, or from their login token when no key is sent
+func identify(r *http.Request) (*http.Request, error) {
+	if secret := apiKeyFromRequest(r); secret != "" {
+		key, err := db.FindAPIKey(hashAPIKey(secret))
+		if err != nil {
+			return r, errInvalidKey
+		}
+		if !keyLimiter.allow(strconv.FormatInt(key.ID, 10), key.RateLimit) {
+			return r, errRateLimited
 		}
+		return withAPIKey(r, key), nil
+	}
 
-		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+	// Checking for JWT in header or cookie
+	tokenString := tokenFromRequest(r)
+	if tokenString == "" {
+		return r, errUnauthorized
+	}
+	claims, err := parseAccessToken(tokenString)
+	if err != nil {
+		return r, err
+	}
+	if active, err := db.SessionActive(claims.SessionID); err != nil || !active {
+		return r, errSessionEnded
+	}
+	return withUserClaims(r, claims), nil
+}
+
+// Passes on userID when the request is authorized, anonymous requests are passed along as they are
+func authMiddlewarePermissive(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorized, err := authenticate(r); err == nil {
+			r = authorized
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
 // Passes userid and fails when not authorized
 func authMiddlewareStrict(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Checking for JWT in header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		authorized, err := authenticate(r)
+		if err == errRateLimited {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		} else if err == errBanned {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
+		next.ServeHTTP(w, authorized)
+	})
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (any, error) {
-			return []byte(SECRET), nil
-		})
-
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+// Rejects requests made with an API key lacking the given scope, goes after authMiddlewareStrict
+func requireScope(scope APIKeyScope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		granted, _ := r.Context().Value(scopeKey).(APIKeyScope)
+		if granted < scope {
+			http.Error(w, "API key is not allowed to "+scope.String(), http.StatusForbidden)
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		claims, ok := token.Claims.(*UserClaims)
-		if !ok {
-			http.Error(w, "Invalid claims", http.StatusUnauthorized)
+// Rejects requests made with an API key, so keys cannot be used to manage keys
+func requireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, usingKey := r.Context().Value(apiKeyIDKey).(int64); usingKey {
+			http.Error(w, "API keys can only be managed after logging in", http.StatusForbidden)
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+// Only let admins through, meant to be wrapped by requireLogin
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(userIDKey).(int64)
+		role, err := db.UserRole(userID)
+		if err != nil {
+			requestLogger(r).Error("Unable to load role", "err", err)
+			http.Error(w, "Unable to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if role != ROLE_ADMIN {
+			http.Error(w, "Only admins can do this", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
@@ -1,8 +1,11 @@
 package internal
 
 import (
+	"errors"
+	"fmt"
 	"js-bet/internal/game"
-	"log"
+	"slices"
+	"sync"
 )
 
 type BetDetails struct {
@@ -11,40 +14,381 @@ type BetDetails struct {
 }
 
 var Bets map[string]BetDetails = make(map[string]BetDetails, 10)
+var betsMu sync.Mutex
+var duelBettingOpen bool
 
-func SetBet(name string, amount int, side bool) {
+var errNotEnoughGold = errors.New("error: not enough gold")
+
+// Held while placing a bet, so two bets at once cannot both be covered by the same gold
+var placeBetMu sync.Mutex
+
+/*
+Make sure the user has the gold to cover a bet along with the bets they have open on other markets
+
+Gold is only taken when bets are settled, so every open bet has to fit in the user's balance. The new bet replaces
+the user's earlier one on the same market, whose lock the caller holds, so that market is left out
+*/
+func checkBalance(name string, market string, amount int) error {
+	staked := amount
+	if market != "duel" {
+		betsMu.Lock()
+		staked += Bets[name].BetAmount
+		betsMu.Unlock()
+	}
+	if market != "champion" {
+		championBetsMu.Lock()
+		staked += ChampionBets[name].BetAmount
+		championBetsMu.Unlock()
+	}
+	if market != "royale" {
+		royaleBetsMu.Lock()
+		staked += RoyaleBets[name].BetAmount
+		royaleBetsMu.Unlock()
+	}
+	gold, err := db.GetUserGold(name)
+	if err != nil {
+		return fmt.Errorf("error: unable to look up gold: %w", err)
+	}
+	if gold < staked {
+		return fmt.Errorf("%w, %d gold does not cover %d staked", errNotEnoughGold, gold, staked)
+	}
+	return nil
+}
+
+func SetBet(name string, amount int, side bool) error {
+	placeBetMu.Lock()
+	defer placeBetMu.Unlock()
+	betsMu.Lock()
+	defer betsMu.Unlock()
+	if !duelBettingOpen {
+		return fmt.Errorf("error: betting is closed")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("error: bet amount must be positive")
+	}
+	if err := checkBalance(name, "duel", amount); err != nil {
+		return err
+	}
 	Bets[name] = BetDetails{amount, side}
-	var sideStr string
+	betsPlaced.Inc("duel")
+	goldWagered.Add(float64(amount), "duel")
+	sideStr := "right"
 	if side {
-		sideStr = "Left"
-	} else {
-		sideStr = "Right"
+		sideStr = "left"
 	}
-	log.Printf("%s Bet on %s with an amount of %d", name, sideStr, amount)
+	arenaLogger.Info("Bet placed", "user", name, "market", "duel", "side", sideStr, "amount", amount)
+	return nil
 }
 
 func ClearBets() {
+	betsMu.Lock()
+	defer betsMu.Unlock()
 	for k := range Bets {
 		delete(Bets, k)
 	}
 }
 
-func AwardBet(name string, winnerResult game.WinnerEnum) {
-	details := Bets[name]
+// Gold won or lost by a bet on a duel, a winning bet pays out even money
+func AwardBet(details BetDetails, winnerResult game.WinnerEnum) int {
 	if details.BetSide == true && winnerResult == game.LEFT {
 		// Award money to this player
+		return details.BetAmount
 	} else if details.BetSide == false && winnerResult == game.RIGHT {
 		// Award money
+		return details.BetAmount
 	}
 	// Give nothing
+	return -details.BetAmount
+}
+
+// Settle a bet with the user's gold, announcing big wins to webhooks
+func payOut(name string, market string, difference int) error {
+	if err := db.ChangeUserGold(name, difference); err != nil {
+		return err
+	}
+	if difference >= BIG_WIN_THRESHOLD {
+		go emitWebhook(WEBHOOK_BIG_WIN, BigWin{User: name, Market: market, Amount: difference})
+	}
+	return nil
 }
 
 func AwardBets(winner game.WinnerEnum) {
-	log.Print(sseHub.clients)
+	betsMu.Lock()
+	defer betsMu.Unlock()
 	// For each name in our map of Bets, award that user with double the amount they put in if they succeeded.
 	// Otherwise, reduce their gold by the amount they bet
-	// for key, val := range Bets {
+	settled, wagered := len(Bets), 0
+	for name, details := range Bets {
+		wagered += details.BetAmount
+		if err := payOut(name, "duel", AwardBet(details, winner)); err != nil {
+			arenaLogger.Error("Unable to settle bet", "user", name, "market", "duel", "err", err)
+		}
+		delete(Bets, name)
+	}
+	observeRoundBets("duel", settled, wagered)
+}
+
+// Open every betting window that applies to the upcoming round
+func OpenBetting(gs game.GameState) {
+	betsMu.Lock()
+	duelBettingOpen = !gs.FreeForAll()
+	betsMu.Unlock()
+	if gs.FreeForAll() {
+		OpenRoyaleBetting(gs)
+	}
+	if gs.Tournament != nil && gs.Tournament.NotStarted() {
+		OpenChampionBetting(gs.Tournament)
+	}
+}
+
+func CloseBetting() {
+	betsMu.Lock()
+	duelBettingOpen = false
+	betsMu.Unlock()
+	CloseRoyaleBetting()
+	CloseChampionBetting()
+}
+
+// Drop the bets on a round that was called off, gold is only taken when bets are settled so nobody loses any
+func VoidRoundBets() {
+	ClearBets()
+	royaleBetsMu.Lock()
+	defer royaleBetsMu.Unlock()
+	for name := range RoyaleBets {
+		delete(RoyaleBets, name)
+	}
+}
+
+// Settle every bet decided by the round that just ended
+func SettleBets(gs game.GameState) {
+	if gs.FreeForAll() {
+		SettleRoyaleBets(gs)
+	} else {
+		AwardBets(gs.Winner)
+	}
+	if gs.Tournament != nil && gs.Tournament.Finished() {
+		SettleChampionBets(gs.Tournament)
+	}
+}
+
+// Outright bet on which fighter will win the whole tournament
+type ChampionBetDetails struct {
+	BetAmount int
+	Fighter   string
+}
+
+var ChampionBets map[string]ChampionBetDetails = make(map[string]ChampionBetDetails, 10)
+var championBetsMu sync.Mutex
+var championField []string // Entrants that can be bet on, nil while outright betting is closed
+
+// Outright bets are only accepted before the first match of a tournament begins
+func OpenChampionBetting(t *game.Tournament) {
+	championBetsMu.Lock()
+	defer championBetsMu.Unlock()
+	championField = t.Entrants
+}
+
+func CloseChampionBetting() {
+	championBetsMu.Lock()
+	defer championBetsMu.Unlock()
+	championField = nil
+}
+
+func championBettingOpen() bool {
+	championBetsMu.Lock()
+	defer championBetsMu.Unlock()
+	return championField != nil
+}
+
+func SetChampionBet(name string, amount int, fighter string) error {
+	placeBetMu.Lock()
+	defer placeBetMu.Unlock()
+	championBetsMu.Lock()
+	defer championBetsMu.Unlock()
+	if championField == nil {
+		return fmt.Errorf("error: outright betting is closed")
+	}
+	if !slices.Contains(championField, fighter) {
+		return fmt.Errorf("error: %s is not entered in the tournament", fighter)
+	}
+	if amount <= 0 {
+		return fmt.Errorf("error: bet amount must be positive")
+	}
+	if err := checkBalance(name, "champion", amount); err != nil {
+		return err
+	}
+	ChampionBets[name] = ChampionBetDetails{amount, fighter}
+	betsPlaced.Inc("champion")
+	goldWagered.Add(float64(amount), "champion")
+	arenaLogger.Info("Bet placed", "user", name, "market", "champion", "fighter", fighter, "amount", amount)
+	return nil
+}
+
+// Pay out outright bets at odds equal to the size of the field, losing bets are taken from the user's gold
+func SettleChampionBets(t *game.Tournament) {
+	championBetsMu.Lock()
+	defer championBetsMu.Unlock()
+	settled, wagered := len(ChampionBets), 0
+	for name, details := range ChampionBets {
+		wagered += details.BetAmount
+		difference := -details.BetAmount
+		if details.Fighter == t.Champion {
+			difference = details.BetAmount * (len(t.Entrants) - 1)
+		}
+		if err := payOut(name, "champion", difference); err != nil {
+			arenaLogger.Error("Unable to settle bet", "user", name, "market", "champion", "tournament_id", t.ID, "err", err)
+		}
+		delete(ChampionBets, name)
+	}
+	observeRoundBets("champion", settled, wagered)
+}
+
+type RoyaleBetKind uint
 
-	// }
-	// Then, for each client currently connected, send them an html update of their client state
+const (
+	_             = iota
+	LAST_STANDING // 1
+	TOP_THREE     // 2
+)
+
+func (k RoyaleBetKind) String() string {
+	switch k {
+	case LAST_STANDING:
+		return "winner"
+	case TOP_THREE:
+		return "top3"
+	}
+	return ""
+}
+
+func parseRoyaleBetKind(s string) (RoyaleBetKind, bool) {
+	switch s {
+	case "winner":
+		return LAST_STANDING, true
+	case "top3":
+		return TOP_THREE, true
+	}
+	return 0, false
+}
+
+// Bet on a fighter's finishing position in a battle royale
+type RoyaleBetDetails struct {
+	BetAmount int
+	Fighter   string
+	Kind      RoyaleBetKind
+}
+
+var RoyaleBets map[string]RoyaleBetDetails = make(map[string]RoyaleBetDetails, 10)
+var royaleBetsMu sync.Mutex
+var royaleField []string // Fighters that can be bet on, nil while royale betting is closed
+
+// Royale bets are only accepted during the pre-round phase
+func OpenRoyaleBetting(gs game.GameState) {
+	royaleBetsMu.Lock()
+	defer royaleBetsMu.Unlock()
+	royaleField = []string{}
+	for _, fighter := range gs.AllFighters() {
+		royaleField = append(royaleField, fighter.Name)
+	}
+}
+
+func CloseRoyaleBetting() {
+	royaleBetsMu.Lock()
+	defer royaleBetsMu.Unlock()
+	royaleField = nil
+}
+
+func royaleBettingOpen() bool {
+	royaleBetsMu.Lock()
+	defer royaleBetsMu.Unlock()
+	return royaleField != nil
+}
+
+func SetRoyaleBet(name string, amount int, fighter string, kind RoyaleBetKind) error {
+	placeBetMu.Lock()
+	defer placeBetMu.Unlock()
+	royaleBetsMu.Lock()
+	defer royaleBetsMu.Unlock()
+	if royaleField == nil {
+		return fmt.Errorf("error: battle royale betting is closed")
+	}
+	if !slices.Contains(royaleField, fighter) {
+		return fmt.Errorf("error: %s is not fighting in this battle royale", fighter)
+	}
+	if amount <= 0 {
+		return fmt.Errorf("error: bet amount must be positive")
+	}
+	if err := checkBalance(name, "royale", amount); err != nil {
+		return err
+	}
+	RoyaleBets[name] = RoyaleBetDetails{amount, fighter, kind}
+	betsPlaced.Inc("royale")
+	goldWagered.Add(float64(amount), "royale")
+	arenaLogger.Info("Bet placed", "user", name, "market", "royale", "fighter", fighter, "kind", kind.String(), "amount", amount)
+	return nil
+}
+
+/*
+Winnings of a royale bet that came in, in a field of n fighters picking the last one standing pays n-1 to 1
+and a top three finish pays (n-3) to 3
+*/
+func royaleWinnings(details RoyaleBetDetails, fieldSize int) int {
+	switch details.Kind {
+	case TOP_THREE:
+		return max(details.BetAmount*(fieldSize-3)/3, 1)
+	default: // LAST_STANDING
+		return details.BetAmount * (fieldSize - 1)
+	}
+}
+
+// Pay out royale bets once every placement is known, losing bets are taken from the user's gold
+func SettleRoyaleBets(gs game.GameState) {
+	royaleBetsMu.Lock()
+	defer royaleBetsMu.Unlock()
+	settled, wagered := len(RoyaleBets), 0
+	for name, details := range RoyaleBets {
+		wagered += details.BetAmount
+		placement := 0
+		for i, team := range gs.Teams {
+			if team.Members[0].Name == details.Fighter {
+				placement = gs.Placement(i)
+			}
+		}
+		difference := -details.BetAmount
+		if (details.Kind == LAST_STANDING && placement == 1) || (details.Kind == TOP_THREE && placement >= 1 && placement <= 3) {
+			difference = royaleWinnings(details, len(gs.Teams))
+		}
+		if err := payOut(name, "royale", difference); err != nil {
+			arenaLogger.Error("Unable to settle bet", "user", name, "market", "royale", "round", gs.Round, "err", err)
+		}
+		delete(RoyaleBets, name)
+	}
+	observeRoundBets("royale", settled, wagered)
+}
+
+// Bets a user has placed that are still waiting on a result, nil for markets without a bet from the user
+type OpenBets struct {
+	Duel     *BetDetails
+	Champion *ChampionBetDetails
+	Royale   *RoyaleBetDetails
+}
+
+func BetsOf(name string) OpenBets {
+	var open OpenBets
+	betsMu.Lock()
+	if details, ok := Bets[name]; ok {
+		open.Duel = &details
+	}
+	betsMu.Unlock()
+	championBetsMu.Lock()
+	if details, ok := ChampionBets[name]; ok {
+		open.Champion = &details
+	}
+	championBetsMu.Unlock()
+	royaleBetsMu.Lock()
+	if details, ok := RoyaleBets[name]; ok {
+		open.Royale = &details
+	}
+	royaleBetsMu.Unlock()
+	return open
 }
@@ -0,0 +1,20 @@
+package internal
+
+import (
+	"js-bet/internal/game"
+)
+
+// Options used to start the server, set from command line flags in cmd/main.go
+type Config struct {
+	TournamentFormat game.TournamentFormat // Zero keeps the winner and draws a challenger each round
+	Matchmaker       game.Matchmaker       // Policy used to draw challengers outside of tournament mode
+	TeamSize         int                   // Fighters on each side of a battle, tournaments are always one-vs-one
+	RoyaleSize       int                   // Fighters in a free-for-all battle royale, zero to fight duels instead
+	Admins           []string              // Names of users promoted to admins at startup
+	Arena            string                // Name of the arena, attached to everything the game logs
+	StaticDir        string                // Directory to serve static files from instead of the embedded ones
+	Dev              bool                  // Watch the static files and components, reloading browsers when they change
+	Production       bool                  // Served over https, so cookies are marked secure and HSTS is sent
+	RateLimits       RateLimits            // How often logins and bets are accepted from the same client
+	SigningKeys      []SigningKey          // Keys tokens are signed with, the first signs new tokens
+}
@@ -1,8 +1,15 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"js-bet/internal/game"
 	"log"
+	"log/slog"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -26,6 +33,14 @@ func CreateClient() DBClient {
 	}
 }
 
+// Check the database can still be reached
+func (db *DBClient) Ping(ctx context.Context) error {
+	if db.conn == nil {
+		return fmt.Errorf("error: database is not open")
+	}
+	return db.conn.PingContext(ctx)
+}
+
 func (db *DBClient) InitDB() error {
 	dbInitStatement := `
 		CREATE TABLE IF NOT EXISTS Users (
@@ -36,26 +51,107 @@ func (db *DBClient) InitDB() error {
 			UNIQUE(id),
 			UNIQUE(name)
 		);
+		CREATE TABLE IF NOT EXISTS Tournaments (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			format INTEGER NOT NULL,
+			state TEXT NOT NULL,
+			champion TEXT NOT NULL DEFAULT ''
+		);
+		CREATE TABLE IF NOT EXISTS FighterRatings (
+			name TEXT NOT NULL PRIMARY KEY,
+			rating INTEGER NOT NULL,
+			change INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS ApiKeys (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES Users(id),
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			hash TEXT NOT NULL UNIQUE,
+			scope INTEGER NOT NULL,
+			rate_limit INTEGER NOT NULL,
+			created_at INTEGER NOT NULL,
+			revoked INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS Webhooks (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS WebhookDeliveries (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL REFERENCES Webhooks(id),
+			event TEXT NOT NULL,
+			attempt INTEGER NOT NULL,
+			status_code INTEGER NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			delivered_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS Sessions (
+			id TEXT NOT NULL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES Users(id),
+			refresh_hash TEXT NOT NULL,
+			previous_hash TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			rotated_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL,
+			revoked INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS GoldLedger (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES Users(id),
+			amount INTEGER NOT NULL,
+			reason TEXT NOT NULL,
+			admin_id INTEGER NOT NULL REFERENCES Users(id),
+			created_at INTEGER NOT NULL
+		);
 	`
 	_, err := db.conn.Exec(dbInitStatement)
+	if err != nil {
+		return err
+	}
+	if err = db.addColumn("Users", "bot", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err = db.addColumn("Users", "role", "TEXT NOT NULL DEFAULT 'user'"); err != nil {
+		return err
+	}
+	return db.addColumn("Users", "banned", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// Add a column to a table created by an older version of the server, does nothing when the column exists
+func (db *DBClient) addColumn(table string, column string, definition string) error {
+	rows, err := db.conn.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s');", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	_, err = db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition))
 	return err
 }
 
 func (db *DBClient) GetUserGold(name string) (int, error) {
+	defer observeQuery("GetUserGold", time.Now())
 	var gold int
 	queryString := `
 		SELECT gold FROM Users WHERE name = ?;
 	`
-	transaction, err := db.conn.Begin()
-	defer db.conn.Close()
-	if err != nil {
-		return 0, err
-	}
-	statement, err := transaction.Prepare(queryString)
-	if err != nil {
-		return 0, err
-	}
-	err = statement.QueryRow(name).Scan(&gold)
+	err := db.conn.QueryRow(queryString, name).Scan(&gold)
 	if err != nil {
 		return 0, err
 	}
@@ -63,41 +159,44 @@ func (db *DBClient) GetUserGold(name string) (int, error) {
 }
 
 func (db *DBClient) ChangeUserGold(name string, difference int) error {
+	defer observeQuery("ChangeUserGold", time.Now())
 	updateStatement := `
 			UPDATE Users SET gold = gold + ? WHERE name = ?;
 	`
-	transaction, err := db.conn.Begin()
-	defer db.conn.Close()
-	if err != nil {
-		return err
-	}
-	statement, err := transaction.Prepare(updateStatement)
-	if err != nil {
-		return err
-	}
-	_, err = statement.Exec(difference, name)
+	_, err := db.conn.Exec(updateStatement, difference, name)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (db *DBClient) UserExists(name string) (bool, error) {
+	defer observeQuery("UserExists", time.Now())
+	var exists bool
+	err := db.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM Users WHERE name == ?);`, name).Scan(&exists)
+	return exists, err
+}
+
+// Find the user with the given name and password, creating them when the name is new
 func (db *DBClient) CheckAddUser(name string, pass string) (int64, error) {
+	defer observeQuery("CheckAddUser", time.Now())
 	selectStatement := `
 		SELECT id FROM Users WHERE name == ? AND pass == ?;
 	`
-	foundUser := db.conn.QueryRow(selectStatement, name, pass)
 	var foundId int64
-	err := foundUser.Scan(&foundId)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("User attempted to login but was not found, resorting to create the user")
-		} else {
-			return foundId, nil
-		}
-	} else {
+	err := db.conn.QueryRow(selectStatement, name, pass).Scan(&foundId)
+	if err == nil {
 		return foundId, nil
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+	exists, err := db.UserExists(name)
+	if err != nil {
+		return 0, err
+	} else if exists {
+		return 0, errWrongPassword
 	}
+	slog.Info("Creating user on first login", "user", name)
 
 	insertStatement := `
 		INSERT INTO Users (name, pass, gold) VALUES (?, ?, ?);
@@ -112,3 +211,500 @@ func (db *DBClient) CheckAddUser(name string, pass string) (int64, error) {
 	}
 	return id, nil
 }
+
+// Insert a new tournament or update the bracket state of an existing one
+func (db *DBClient) SaveTournament(t *game.Tournament) error {
+	defer observeQuery("SaveTournament", time.Now())
+	state, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if t.ID != 0 {
+		updateStatement := `
+			UPDATE Tournaments SET state = ?, champion = ? WHERE id = ?;
+		`
+		_, err = db.conn.Exec(updateStatement, state, t.Champion, t.ID)
+		return err
+	}
+
+	insertStatement := `
+		INSERT INTO Tournaments (format, state, champion) VALUES (?, ?, ?);
+	`
+	inserted, err := db.conn.Exec(insertStatement, t.Format, state, t.Champion)
+	if err != nil {
+		return err
+	}
+	t.ID, err = inserted.LastInsertId()
+	return err
+}
+
+// Load the most recent tournament without a champion, returns nil when there is none to resume
+func (db *DBClient) LoadActiveTournament(format game.TournamentFormat) (*game.Tournament, error) {
+	defer observeQuery("LoadActiveTournament", time.Now())
+	selectStatement := `
+		SELECT id, state FROM Tournaments WHERE champion = '' AND format = ? ORDER BY id DESC LIMIT 1;
+	`
+	var id int64
+	var state []byte
+	err := db.conn.QueryRow(selectStatement, format).Scan(&id, &state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	t := &game.Tournament{}
+	if err = json.Unmarshal(state, t); err != nil {
+		return nil, err
+	}
+	t.ID = id
+	return t, nil
+}
+
+func (db *DBClient) SaveFighterRatings(fighters ...game.Fighter) error {
+	defer observeQuery("SaveFighterRatings", time.Now())
+	upsertStatement := `
+		INSERT INTO FighterRatings (name, rating, change) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET rating = excluded.rating, change = excluded.change;
+	`
+	for _, fighter := range fighters {
+		_, err := db.conn.Exec(upsertStatement, fighter.Name, fighter.Rating.Value, fighter.Rating.Change)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DBClient) LoadFighterRatings() (map[string]game.Rating, error) {
+	defer observeQuery("LoadFighterRatings", time.Now())
+	selectStatement := `
+		SELECT name, rating, change FROM FighterRatings;
+	`
+	rows, err := db.conn.Query(selectStatement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := make(map[string]game.Rating)
+	for rows.Next() {
+		var name string
+		var rating game.Rating
+		if err = rows.Scan(&name, &rating.Value, &rating.Change); err != nil {
+			return nil, err
+		}
+		ratings[name] = rating
+	}
+	return ratings, rows.Err()
+}
+
+func (db *DBClient) CreateAPIKey(key *APIKey, hash string) error {
+	defer observeQuery("CreateAPIKey", time.Now())
+	insertStatement := `
+		INSERT INTO ApiKeys (user_id, name, prefix, hash, scope, rate_limit, created_at) VALUES (?, ?, ?, ?, ?, ?, ?);
+	`
+	inserted, err := db.conn.Exec(insertStatement, key.UserID, key.Name, key.Prefix, hash, key.Scope, key.RateLimit, key.CreatedAt.Unix())
+	if err != nil {
+		return err
+	}
+	key.ID, err = inserted.LastInsertId()
+	return err
+}
+
+// Keys of a user that have not been revoked, oldest first
+func (db *DBClient) ListAPIKeys(userID int64) ([]APIKey, error) {
+	defer observeQuery("ListAPIKeys", time.Now())
+	selectStatement := `
+		SELECT k.id, k.user_id, u.name, k.name, k.prefix, k.scope, k.rate_limit, k.created_at
+		FROM ApiKeys k JOIN Users u ON u.id = k.user_id
+		WHERE k.user_id = ? AND k.revoked = 0 ORDER BY k.id;
+	`
+	rows, err := db.conn.Query(selectStatement, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Find the key with the given hash, returns sql.ErrNoRows when it does not exist or has been revoked
+func (db *DBClient) FindAPIKey(hash string) (APIKey, error) {
+	defer observeQuery("FindAPIKey", time.Now())
+	selectStatement := `
+		SELECT k.id, k.user_id, u.name, k.name, k.prefix, k.scope, k.rate_limit, k.created_at
+		FROM ApiKeys k JOIN Users u ON u.id = k.user_id
+		WHERE k.hash = ? AND k.revoked = 0;
+	`
+	return scanAPIKey(db.conn.QueryRow(selectStatement, hash))
+}
+
+// Revoke one of a user's keys, returns false when the user has no such key
+func (db *DBClient) RevokeAPIKey(userID int64, keyID int64) (bool, error) {
+	defer observeQuery("RevokeAPIKey", time.Now())
+	updateStatement := `
+		UPDATE ApiKeys SET revoked = 1 WHERE id = ? AND user_id = ? AND revoked = 0;
+	`
+	result, err := db.conn.Exec(updateStatement, keyID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (APIKey, error) {
+	var key APIKey
+	var createdAt int64
+	err := row.Scan(&key.ID, &key.UserID, &key.UserName, &key.Name, &key.Prefix, &key.Scope, &key.RateLimit, &createdAt)
+	key.CreatedAt = time.Unix(createdAt, 0)
+	return key, err
+}
+
+func (db *DBClient) CreateSession(session Session, hash string) error {
+	defer observeQuery("CreateSession", time.Now())
+	insertStatement := `
+		INSERT INTO Sessions (id, user_id, refresh_hash, user_agent, created_at, rotated_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?);
+	`
+	_, err := db.conn.Exec(insertStatement, session.ID, session.UserID, hash, session.UserAgent,
+		session.CreatedAt.Unix(), session.RotatedAt.Unix(), session.ExpiresAt.Unix())
+	return err
+}
+
+// Find a session that has neither been revoked nor expired, returns sql.ErrNoRows otherwise
+func (db *DBClient) FindSession(id string) (Session, error) {
+	defer observeQuery("FindSession", time.Now())
+	selectStatement := `
+		SELECT s.id, s.user_id, u.name, s.refresh_hash, s.previous_hash, s.user_agent, s.created_at, s.rotated_at, s.expires_at
+		FROM Sessions s JOIN Users u ON u.id = s.user_id
+		WHERE s.id = ? AND s.revoked = 0 AND s.expires_at > ?;
+	`
+	return scanSession(db.conn.QueryRow(selectStatement, id, time.Now().Unix()))
+}
+
+func (db *DBClient) SessionActive(id string) (bool, error) {
+	defer observeQuery("SessionActive", time.Now())
+	var active bool
+	selectStatement := `
+		SELECT EXISTS(SELECT 1 FROM Sessions WHERE id = ? AND revoked = 0 AND expires_at > ?);
+	`
+	err := db.conn.QueryRow(selectStatement, id, time.Now().Unix()).Scan(&active)
+	return active, err
+}
+
+// Active sessions of a user, most recently used first
+func (db *DBClient) ListSessions(userID int64) ([]Session, error) {
+	defer observeQuery("ListSessions", time.Now())
+	selectStatement := `
+		SELECT s.id, s.user_id, u.name, s.refresh_hash, s.previous_hash, s.user_agent, s.created_at, s.rotated_at, s.expires_at
+		FROM Sessions s JOIN Users u ON u.id = s.user_id
+		WHERE s.user_id = ? AND s.revoked = 0 AND s.expires_at > ? ORDER BY s.rotated_at DESC;
+	`
+	rows, err := db.conn.Query(selectStatement, userID, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+/*
+Replace the refresh token of a session, keeping the hash of the one it replaces
+
+Returns false when the session no longer has the expected token, which happens when another request rotated it first
+*/
+func (db *DBClient) RotateSession(id string, oldHash string, newHash string, now time.Time) (bool, error) {
+	defer observeQuery("RotateSession", time.Now())
+	updateStatement := `
+		UPDATE Sessions SET previous_hash = refresh_hash, refresh_hash = ?, rotated_at = ?
+		WHERE id = ? AND refresh_hash = ? AND revoked = 0;
+	`
+	result, err := db.conn.Exec(updateStatement, newHash, now.Unix(), id, oldHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (db *DBClient) RevokeSession(id string) error {
+	defer observeQuery("RevokeSession", time.Now())
+	_, err := db.conn.Exec(`UPDATE Sessions SET revoked = 1 WHERE id = ?;`, id)
+	return err
+}
+
+// Log a user out everywhere, returns how many sessions were still active
+func (db *DBClient) RevokeUserSessions(userID int64) (int64, error) {
+	defer observeQuery("RevokeUserSessions", time.Now())
+	result, err := db.conn.Exec(`UPDATE Sessions SET revoked = 1 WHERE user_id = ? AND revoked = 0;`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanSession(row interface{ Scan(dest ...any) error }) (Session, error) {
+	var session Session
+	var createdAt, rotatedAt, expiresAt int64
+	err := row.Scan(&session.ID, &session.UserID, &session.UserName, &session.RefreshHash, &session.PreviousHash,
+		&session.UserAgent, &createdAt, &rotatedAt, &expiresAt)
+	session.CreatedAt = time.Unix(createdAt, 0)
+	session.RotatedAt = time.Unix(rotatedAt, 0)
+	session.ExpiresAt = time.Unix(expiresAt, 0)
+	return session, err
+}
+
+// Bot accounts are ranked separately from humans
+func (db *DBClient) SetUserBot(userID int64, bot bool) error {
+	defer observeQuery("SetUserBot", time.Now())
+	updateStatement := `
+		UPDATE Users SET bot = ? WHERE id = ?;
+	`
+	_, err := db.conn.Exec(updateStatement, bot, userID)
+	return err
+}
+
+func (db *DBClient) IsUserBot(name string) (bool, error) {
+	defer observeQuery("IsUserBot", time.Now())
+	var bot bool
+	err := db.conn.QueryRow(`SELECT bot FROM Users WHERE name = ?;`, name).Scan(&bot)
+	return bot, err
+}
+
+type LeaderboardEntry struct {
+	Name string `json:"name"`
+	Gold int    `json:"gold"`
+}
+
+// Richest accounts, either only bots or only humans
+func (db *DBClient) Leaderboard(bots bool, limit int) ([]LeaderboardEntry, error) {
+	defer observeQuery("Leaderboard", time.Now())
+	selectStatement := `
+		SELECT name, gold FROM Users WHERE bot = ? ORDER BY gold DESC, name LIMIT ?;
+	`
+	rows, err := db.conn.Query(selectStatement, bots, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LeaderboardEntry{}
+	for rows.Next() {
+		var entry LeaderboardEntry
+		if err = rows.Scan(&entry.Name, &entry.Gold); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+const ROLE_USER = "user"
+const ROLE_ADMIN = "admin"
+
+// Returns false when there is no user with the given name
+func (db *DBClient) SetUserRole(name string, role string) (bool, error) {
+	defer observeQuery("SetUserRole", time.Now())
+	result, err := db.conn.Exec(`UPDATE Users SET role = ? WHERE name = ?;`, role, name)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (db *DBClient) UserRole(userID int64) (string, error) {
+	defer observeQuery("UserRole", time.Now())
+	var role string
+	err := db.conn.QueryRow(`SELECT role FROM Users WHERE id = ?;`, userID).Scan(&role)
+	return role, err
+}
+
+// Returns false when there is no user with the given name
+func (db *DBClient) SetUserBanned(name string, banned bool) (bool, error) {
+	defer observeQuery("SetUserBanned", time.Now())
+	result, err := db.conn.Exec(`UPDATE Users SET banned = ? WHERE name = ?;`, banned, name)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (db *DBClient) IsUserBanned(userID int64) (bool, error) {
+	defer observeQuery("IsUserBanned", time.Now())
+	var banned bool
+	err := db.conn.QueryRow(`SELECT banned FROM Users WHERE id = ?;`, userID).Scan(&banned)
+	return banned, err
+}
+
+// Change to a user's gold made by an admin rather than by a bet
+type LedgerEntry struct {
+	ID        int64
+	UserName  string
+	Amount    int
+	Reason    string
+	AdminName string
+	CreatedAt time.Time
+}
+
+// Change a user's gold and record why in the ledger, returns false when there is no user with the given name
+func (db *DBClient) AdjustUserGold(name string, amount int, reason string, adminID int64) (bool, error) {
+	defer observeQuery("AdjustUserGold", time.Now())
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRow(`SELECT id FROM Users WHERE name = ?;`, name).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if _, err = tx.Exec(`UPDATE Users SET gold = gold + ? WHERE id = ?;`, amount, userID); err != nil {
+		return false, err
+	}
+	insertStatement := `
+		INSERT INTO GoldLedger (user_id, amount, reason, admin_id, created_at) VALUES (?, ?, ?, ?, ?);
+	`
+	if _, err = tx.Exec(insertStatement, userID, amount, reason, adminID, time.Now().Unix()); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Latest changes made to users' gold by admins, newest first
+func (db *DBClient) GoldLedger(limit int) ([]LedgerEntry, error) {
+	defer observeQuery("GoldLedger", time.Now())
+	selectStatement := `
+		SELECT GoldLedger.id, Users.name, amount, reason, Admins.name, created_at FROM GoldLedger
+		JOIN Users ON Users.id = GoldLedger.user_id
+		JOIN Users AS Admins ON Admins.id = GoldLedger.admin_id
+		ORDER BY GoldLedger.id DESC LIMIT ?;
+	`
+	rows, err := db.conn.Query(selectStatement, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LedgerEntry{}
+	for rows.Next() {
+		var entry LedgerEntry
+		var createdAt int64
+		if err = rows.Scan(&entry.ID, &entry.UserName, &entry.Amount, &entry.Reason, &entry.AdminName, &createdAt); err != nil {
+			return nil, err
+		}
+		entry.CreatedAt = time.Unix(createdAt, 0)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (db *DBClient) CreateWebhook(hook *Webhook) error {
+	defer observeQuery("CreateWebhook", time.Now())
+	insertStatement := `
+		INSERT INTO Webhooks (url, secret, events, created_at) VALUES (?, ?, ?, ?);
+	`
+	inserted, err := db.conn.Exec(insertStatement, hook.URL, hook.Secret, strings.Join(hook.Events, ","), hook.CreatedAt.Unix())
+	if err != nil {
+		return err
+	}
+	hook.ID, err = inserted.LastInsertId()
+	return err
+}
+
+func (db *DBClient) ListWebhooks() ([]Webhook, error) {
+	defer observeQuery("ListWebhooks", time.Now())
+	rows, err := db.conn.Query(`SELECT id, url, secret, events, created_at FROM Webhooks ORDER BY id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		var hook Webhook
+		var events string
+		var createdAt int64
+		if err = rows.Scan(&hook.ID, &hook.URL, &hook.Secret, &events, &createdAt); err != nil {
+			return nil, err
+		}
+		if events != "" {
+			hook.Events = strings.Split(events, ",")
+		}
+		hook.CreatedAt = time.Unix(createdAt, 0)
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// Returns false when there is no webhook with the given id
+func (db *DBClient) DeleteWebhook(id int64) (bool, error) {
+	defer observeQuery("DeleteWebhook", time.Now())
+	_, err := db.conn.Exec(`DELETE FROM WebhookDeliveries WHERE webhook_id = ?;`, id)
+	if err != nil {
+		return false, err
+	}
+	result, err := db.conn.Exec(`DELETE FROM Webhooks WHERE id = ?;`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (db *DBClient) LogWebhookDelivery(delivery WebhookDelivery) error {
+	defer observeQuery("LogWebhookDelivery", time.Now())
+	insertStatement := `
+		INSERT INTO WebhookDeliveries (webhook_id, event, attempt, status_code, error, delivered_at) VALUES (?, ?, ?, ?, ?, ?);
+	`
+	_, err := db.conn.Exec(insertStatement, delivery.WebhookID, delivery.Event, delivery.Attempt, delivery.StatusCode, delivery.Error, delivery.DeliveredAt.Unix())
+	return err
+}
+
+// Latest delivery attempts of a webhook, newest first
+func (db *DBClient) ListWebhookDeliveries(webhookID int64, limit int) ([]WebhookDelivery, error) {
+	defer observeQuery("ListWebhookDeliveries", time.Now())
+	selectStatement := `
+		SELECT id, webhook_id, event, attempt, status_code, error, delivered_at
+		FROM WebhookDeliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?;
+	`
+	rows, err := db.conn.Query(selectStatement, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var deliveredAt int64
+		if err = rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Attempt, &delivery.StatusCode, &delivery.Error, &deliveredAt); err != nil {
+			return nil, err
+		}
+		delivery.DeliveredAt = time.Unix(deliveredAt, 0)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Directory of the templ components, relative to the repository root the server is started from in development
+const COMPONENTS_DIR = "internal/components"
+
+// How long templ's watcher gets to clean up after being interrupted
+const TEMPL_STOP_TIMEOUT = 2 * time.Second
+
+// Wait for changes to settle before reloading, editors and templ often write several files for one save
+const DEV_RELOAD_DEBOUNCE = 300 * time.Millisecond
+
+// Sent to browsers with the reload event, stylesheets are swapped in place and anything else reloads the page
+type devReload struct {
+	Styles map[string]string `json:"styles,omitempty"` // New urls of the changed stylesheets keyed by their path
+}
+
+/*
+Watch the static files and templ components while developing, pushing a reload event to every browser on the
+game stream when they change
+
+Static files and icons are reloaded without restarting the game. Components are regenerated by templ's own
+watcher, which hot-swaps their text when the server runs with TEMPL_DEV_MODE=true, while changes to their Go
+code still need a restart
+*/
+func watchForDev(ctx context.Context, staticDir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	for _, root := range []string{staticDir, COMPONENTS_DIR} {
+		err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.IsDir() {
+				return err
+			}
+			return watcher.Add(path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if os.Getenv("TEMPL_DEV_MODE") != "true" {
+		slog.Warn("TEMPL_DEV_MODE is not set, changes to components will need a restart")
+	}
+	templStopped := startTempl(ctx)
+
+	staticRoot, _ := filepath.Abs(staticDir)
+	changed := map[string]bool{}
+	settle := time.NewTimer(DEV_RELOAD_DEBOUNCE)
+	settle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			<-templStopped
+			return nil
+		case err := <-watcher.Errors:
+			slog.Warn("Unable to watch for changes", "err", err)
+		case event := <-watcher.Events:
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() && event.Has(fsnotify.Create) {
+				watcher.Add(event.Name)
+			}
+			changed[event.Name] = true
+			settle.Reset(DEV_RELOAD_DEBOUNCE)
+		case <-settle.C:
+			applyDevChanges(staticRoot, changed)
+			clear(changed)
+		}
+	}
+}
+
+/*
+Run templ's watcher over the components until the context is done, the returned channel closes once it stopped
+
+The templ binary is started directly rather than through go tool, which would leave it running when interrupted
+*/
+func startTempl(ctx context.Context) chan struct{} {
+	stopped := make(chan struct{})
+	path, err := exec.Command("go", "tool", "-n", "templ").Output()
+	if err != nil {
+		slog.Warn("Unable to find templ, components will not be regenerated", "err", err)
+		close(stopped)
+		return stopped
+	}
+	templ := exec.CommandContext(ctx, strings.TrimSpace(string(path)), "generate", "-watch", "-path", ".", "-watch-pattern", `(.+\.templ$)`)
+	templ.Stdout, templ.Stderr = os.Stderr, os.Stderr
+	templ.Cancel = func() error { return templ.Process.Signal(os.Interrupt) }
+	templ.WaitDelay = TEMPL_STOP_TIMEOUT
+	if err = templ.Start(); err != nil {
+		slog.Warn("Unable to start templ, components will not be regenerated", "err", err)
+		close(stopped)
+		return stopped
+	}
+	go func() {
+		defer close(stopped)
+		templ.Wait()
+	}()
+	return stopped
+}
+
+// Reload whatever the changed files affect and tell the browsers, icons are picked up by the next tick on their own
+func applyDevChanges(staticRoot string, changed map[string]bool) {
+	reload := devReload{Styles: map[string]string{}}
+	reloadPage := false
+	for path := range changed {
+		switch {
+		case strings.HasSuffix(path, ".templ"):
+			reloadPage = true
+		default:
+			absolute, _ := filepath.Abs(path)
+			relative, err := filepath.Rel(staticRoot, absolute)
+			if err != nil || strings.HasPrefix(relative, "..") {
+				continue
+			}
+			relative = filepath.ToSlash(relative)
+			if filepath.Ext(relative) == ".css" {
+				reload.Styles["/"+relative] = ""
+			} else if !strings.HasPrefix(relative, "icons/") {
+				reloadPage = true
+			}
+		}
+	}
+
+	loaded, err := loadStatic(staticFiles)
+	if err != nil {
+		slog.Error("Unable to reload static files", "err", err)
+		return
+	}
+	site.Store(loaded)
+	for path := range reload.Styles {
+		reload.Styles[path] = loaded.Assets.URL(path)
+	}
+	slog.Info("Reloaded static files", "files", len(changed), "page", reloadPage, "styles", len(reload.Styles))
+	if !reloadPage && len(reload.Styles) == 0 {
+		return
+	}
+	if reloadPage {
+		reload.Styles = nil
+	}
+
+	data, _ := json.Marshal(reload)
+	var frame bytes.Buffer
+	WriteSSENamedEvent(&frame, "reload", data)
+	sseHub.broadcast <- frame.Bytes()
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyDevChanges(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("index.html", `<link href="/styles/index.css" rel="stylesheet">`)
+	write("styles/index.css", "body {}")
+	write("cues.json", "{}")
+	icon := write("icons/React.svg", `<svg viewBox="0 0 1 1">old</svg>`)
+
+	previousFiles, previousSite, previousHub := staticFiles, site.Load(), sseHub
+	t.Cleanup(func() {
+		staticFiles, sseHub = previousFiles, previousHub
+		site.Store(previousSite)
+	})
+	staticFiles = os.DirFS(dir)
+	sseHub = NewHub("game")
+	received := func() string {
+		select {
+		case frame := <-sseHub.broadcast:
+			return string(frame)
+		case <-time.After(100 * time.Millisecond):
+			return ""
+		}
+	}
+
+	write("icons/React.svg", `<svg viewBox="0 0 1 1">new</svg>`)
+	go applyDevChanges(dir, map[string]bool{icon: true})
+	if frame := received(); frame != "" {
+		t.Errorf("icons should be picked up without reloading the page, got %q", frame)
+	}
+	if siteAssets().GetIcon("React") != `<svg viewBox="0 0 1 1">new</svg>` {
+		t.Errorf("expected the icon to be reloaded, got %q", siteAssets().GetIcon("React"))
+	}
+
+	style := write("styles/index.css", "body { margin: 0 }")
+	go applyDevChanges(dir, map[string]bool{style: true})
+	frame := received()
+	if !strings.HasPrefix(frame, "event: reload\n") || !strings.Contains(frame, siteAssets().URL("/styles/index.css")) {
+		t.Errorf("expected the stylesheet to be swapped in place, got %q", frame)
+	}
+	if !strings.Contains(string(site.Load().Homepage), siteAssets().URL("/styles/index.css")) {
+		t.Error("expected the homepage to link the new stylesheet")
+	}
+
+	go applyDevChanges(dir, map[string]bool{filepath.Join(COMPONENTS_DIR, "game.templ"): true})
+	if frame := received(); !strings.Contains(frame, "data: {}") {
+		t.Errorf("expected a component change to reload the page, got %q", frame)
+	}
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// Error a handler returns to answer with a status other than 500, its message is shown to the user
+type httpError struct {
+	Status  int
+	Message string
+}
+
+func (e httpError) Error() string {
+	return e.Message
+}
+
+func errorf(status int, format string, args ...any) error {
+	return httpError{status, fmt.Sprintf(format, args...)}
+}
+
+/*
+Handler that reports failures by returning them instead of writing the response itself
+
+An httpError is written with its own status and message, anything else is logged and answered with a 500 so
+internal details never reach the user
+*/
+type errorHandler func(w http.ResponseWriter, r *http.Request) error
+
+func (h errorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := h(w, r)
+	if err == nil {
+		return
+	}
+	var limitErr rateLimitError
+	if errors.As(err, &limitErr) {
+		writeRateLimited(w, r, limitErr)
+		return
+	}
+	var httpErr httpError
+	if errors.As(err, &httpErr) {
+		requestLogger(r).Debug("Request refused", "status", httpErr.Status, "err", err)
+		http.Error(w, httpErr.Message, httpErr.Status)
+		return
+	}
+	requestLogger(r).Error("Request failed", "err", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+// Answer a request that panicked with a 500 instead of dropping the connection
+func withRecover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			requestLogger(r).Error("Request panicked", "panic", recovered, "stack", string(debug.Stack()))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
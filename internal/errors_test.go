@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMethodRouting(t *testing.T) {
+	mux := routes(http.NotFoundHandler())
+	cases := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/user/login", http.StatusNotFound},
+		{http.MethodPut, "/user/login", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/user/placeBet", http.StatusNotFound},
+		{http.MethodPost, "/game/", http.StatusMethodNotAllowed},
+		{http.MethodPost, API_PREFIX + "/state", http.StatusMethodNotAllowed},
+		{http.MethodPut, API_PREFIX + "/keys", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/user/placeBet", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.status {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.path, c.status, w.Code)
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	handler := withRecover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/refused":
+			errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+				return errorf(http.StatusBadRequest, "Bet amount must be a whole number")
+			}).ServeHTTP(w, r)
+		case "/failed":
+			errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("error: database is locked")
+			}).ServeHTTP(w, r)
+		default:
+			panic("unexpected")
+		}
+	}))
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/refused", http.StatusBadRequest, "Bet amount must be a whole number"},
+		{"/failed", http.StatusInternalServerError, "Internal server error"},
+		{"/panics", http.StatusInternalServerError, "Internal server error"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.status || strings.TrimSpace(w.Body.String()) != c.body {
+			t.Errorf("%s: expected %d %q, got %d %q", c.path, c.status, c.body, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package game
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Identifier of an ability's sound and animation cue in the cue manifest, a slug of the ability's name
+func (a Ability) Cue() string {
+	var builder strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(a.Name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && builder.Len() > 0 {
+				builder.WriteRune('-')
+			}
+			builder.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return builder.String()
+}
+
+// Cues of every ability in the roster
+func AbilityCues() []string {
+	cues := []string{}
+	for _, fighter := range fighterList {
+		for _, ability := range fighter.Abilities {
+			cues = append(cues, ability.Cue())
+		}
+	}
+	return cues
+}
+
+// What a fighter can see of the battle when deciding whether to use an ability
+type AbilityContext struct {
+	Self    *Fighter
+	Allies  *Team
+	Enemies []*Fighter // Living fighters on every other team
+}
+
+// Checked before an ability is used, a ready ability is held until every one of its conditions holds
+type AbilityCondition func(ctx AbilityContext) bool
+
+func healthRatio(f *Fighter) float32 {
+	return float32(f.Health.Value) / float32(f.Health.MaxValue)
+}
+
+// Holds when the user's health is below the given fraction of its maximum
+func SelfHealthBelow(ratio float32) AbilityCondition {
+	return func(ctx AbilityContext) bool {
+		return healthRatio(ctx.Self) < ratio
+	}
+}
+
+// Holds when any living ally, including the user, is below the given fraction of its maximum health
+func AllyHealthBelow(ratio float32) AbilityCondition {
+	return func(ctx AbilityContext) bool {
+		for _, ally := range ctx.Allies.living() {
+			if healthRatio(ally) < ratio {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Holds when any living enemy is below the given fraction of its maximum health
+func EnemyHealthBelow(ratio float32) AbilityCondition {
+	return func(ctx AbilityContext) bool {
+		for _, enemy := range ctx.Enemies {
+			if healthRatio(enemy) < ratio {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Decides whether a fighter uses an ability once it is ready and its conditions hold, or keeps holding it
+type FighterAI interface {
+	ShouldUse(ability Ability, ctx AbilityContext) bool
+}
+
+// Uses every ability as soon as it can, the behaviour of fighters without an AI
+type Eager struct{}
+
+func (Eager) ShouldUse(ability Ability, ctx AbilityContext) bool {
+	return true
+}
+
+/*
+Holds abilities that would mostly go to waste
+
+Support abilities are held while every ally is close to full health, and abilities aimed at a single enemy are
+held when the target is already low enough to be finished off by a regular attack
+*/
+type Tactical struct{}
+
+func (Tactical) ShouldUse(ability Ability, ctx AbilityContext) bool {
+	switch ability.Target {
+	case TARGET_ALLY, TARGET_ALL_ALLIES:
+		return AllyHealthBelow(0.75)(ctx)
+	case TARGET_ENEMY:
+		target := ctx.Self.chooseTarget(ctx.Enemies)
+		return target == nil || target.Health.Value > ctx.Self.Damage.Value
+	}
+	return true
+}
+
+// Whether a ready ability should be used now, checking its conditions and then the fighter's AI
+func (f *Fighter) wantsAbility(ability Ability, ctx AbilityContext) bool {
+	for _, condition := range ability.Conditions {
+		if !condition(ctx) {
+			return false
+		}
+	}
+	if f.AI == nil {
+		return true
+	}
+	return f.AI.ShouldUse(ability, ctx)
+}
+
+// Higher priority abilities are used first, the most overdue ability goes first between equal priorities
+func (a Ability) preferredOver(other Ability) bool {
+	if a.Priority != other.Priority {
+		return a.Priority > other.Priority
+	}
+	return a.HeldTicks > other.HeldTicks
+}
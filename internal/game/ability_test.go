@@ -0,0 +1,86 @@
+package game
+
+import (
+	"testing"
+)
+
+func TestHealHeldAtFullHealth(t *testing.T) {
+	vue, err := chooseFighterByName("Vue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enemy, err := chooseFighterByName("React")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allies := NewTeam(vue)
+	ctx := AbilityContext{Self: &allies.Members[0], Allies: &allies, Enemies: []*Fighter{&enemy}}
+	heal := vue.Abilities[0]
+
+	if allies.Members[0].wantsAbility(heal, ctx) {
+		t.Error("heal should be held at full health")
+	}
+	allies.Members[0].Health.Value = 5
+	if !allies.Members[0].wantsAbility(heal, ctx) {
+		t.Error("heal should be used below half health")
+	}
+}
+
+func TestAbilityPriority(t *testing.T) {
+	urgent := Ability{Priority: 2, HeldTicks: 1}
+	overdue := Ability{Priority: 1, HeldTicks: 5}
+	if !urgent.preferredOver(overdue) {
+		t.Error("higher priority ability should be preferred over a more overdue one")
+	}
+	overdue.Priority = 2
+	if !overdue.preferredOver(urgent) {
+		t.Error("more overdue ability should be preferred between equal priorities")
+	}
+}
+
+func TestTacticalHoldsFinishingBlow(t *testing.T) {
+	self := Fighter{Damage: NewIntStat(5), Health: NewIntStat(10)}
+	enemy := Fighter{Health: IntStat{Value: 4, MaxValue: 20}}
+	allies := NewTeam(self)
+	ctx := AbilityContext{Self: &allies.Members[0], Allies: &allies, Enemies: []*Fighter{&enemy}}
+	strike := Ability{Target: TARGET_ENEMY}
+	if (Tactical{}).ShouldUse(strike, ctx) {
+		t.Error("tactical AI should hold an ability when a regular attack would finish the enemy")
+	}
+	enemy.Health.Value = 15
+	if !(Tactical{}).ShouldUse(strike, ctx) {
+		t.Error("tactical AI should use an ability against a healthy enemy")
+	}
+}
+
+func TestAbilityCue(t *testing.T) {
+	cases := map[string]string{
+		"Virtual DOM":             "virtual-dom",
+		"I am inevitable...":      "i-am-inevitable",
+		"Second most loved, btw!": "second-most-loved-btw",
+	}
+	for name, cue := range cases {
+		if got := (Ability{Name: name}).Cue(); got != cue {
+			t.Errorf("cue of %q is %q, expected %q", name, got, cue)
+		}
+	}
+}
+
+func TestHeldAbilityTimersStopAtZero(t *testing.T) {
+	g := New()
+	for g.Phase != ROUND {
+		g.StepGame()
+	}
+	for g.Phase == ROUND {
+		g.StepGame()
+		for _, team := range g.Teams {
+			for _, member := range team.Members {
+				for _, ability := range member.Abilities {
+					if ability.Timer.Value < 0 {
+						t.Fatalf("%s of %s counted down past zero to %d", ability.Name, member.Name, ability.Timer.Value)
+					}
+				}
+			}
+		}
+	}
+}
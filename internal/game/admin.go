@@ -0,0 +1,70 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+)
+
+/*
+Call off the round being played, or the one about to start, without a winner
+
+Nobody is rated and the arena moves on to the post-round phase, after which a fresh battle is drawn as it is
+after any round without a winner. Once a round is over its bets are settled, so there is nothing left to call off
+*/
+func (g *GameState) AbortRound(reason string) error {
+	if g.Phase != PREROUND && g.Phase != ROUND {
+		return fmt.Errorf("error: the round is already over")
+	}
+	if g.BettingOpen {
+		g.BettingOpen = false
+		g.emit(BETTING_CLOSED, -1)
+	}
+	g.Placements = nil
+	g.Winner = NEITHER
+	g.Phase = POSTROUND
+	g.PhaseTimer = 10
+	g.Status = reason
+	g.emit(ROUND_ABORTED, -1)
+	return nil
+}
+
+/*
+Pick the fighters of the next round instead of drawing them, in the order of the teams
+
+A duel between teams of two takes four names, the first two fighting on the left. Tournaments play their
+bracket, so the matchup cannot be picked while one is running
+*/
+func (g *GameState) SetNextMatchup(names []string) error {
+	if g.Tournament != nil {
+		return fmt.Errorf("error: matchups are decided by the tournament bracket")
+	}
+	if expected := len(g.Teams) * g.TeamSize; len(names) != expected {
+		return fmt.Errorf("error: expected %d fighters for the next round, got %d", expected, len(names))
+	}
+	for i, name := range names {
+		if slices.Contains(names[:i], name) {
+			return fmt.Errorf("error: %s was picked more than once", name)
+		}
+		if _, err := chooseFighterByName(name); err != nil {
+			return err
+		}
+	}
+	g.NextMatchup = names
+	return nil
+}
+
+// Place the fighters picked by SetNextMatchup into the arena
+func (g *GameState) loadNextMatchup() {
+	teams := make([]Team, 0, len(g.Teams))
+	for names := range slices.Chunk(g.NextMatchup, g.TeamSize) {
+		members := make([]Fighter, 0, len(names))
+		for _, name := range names {
+			fighter, _ := chooseFighterByName(name) // Checked when the matchup was picked
+			members = append(members, fighter)
+		}
+		teams = append(teams, NewTeam(members...))
+	}
+	g.Teams = teams
+	g.Placements = nil
+	g.NextMatchup = nil
+}
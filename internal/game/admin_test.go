@@ -0,0 +1,71 @@
+package game
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAbortRound(t *testing.T) {
+	g := New()
+	g.StepGame()
+	g.DrainEvents()
+	ratings := []int{g.Teams[0].Members[0].Rating.Value, g.Teams[1].Members[0].Rating.Value}
+
+	if err := g.AbortRound("Called off"); err != nil {
+		t.Fatal(err)
+	}
+	kinds := []LifecycleEventKind{}
+	for _, event := range g.DrainEvents() {
+		kinds = append(kinds, event.Kind)
+	}
+	if !slices.Equal(kinds, []LifecycleEventKind{BETTING_CLOSED, ROUND_ABORTED}) {
+		t.Errorf("expected betting to close before the round is called off, got %v", kinds)
+	}
+	if g.Phase != POSTROUND || g.WinningTeam() != -1 || g.Status != "Called off" {
+		t.Errorf("expected a post-round phase without a winner, got phase %v and winner %d", g.Phase, g.WinningTeam())
+	}
+	if g.Teams[0].Members[0].Rating.Value != ratings[0] || g.Teams[1].Members[0].Rating.Value != ratings[1] {
+		t.Error("nobody should be rated for a round that was called off")
+	}
+	if err := g.AbortRound("Called off again"); err == nil || g.Status != "Called off" {
+		t.Errorf("a round that is already over should not be called off, got %v with status %q", err, g.Status)
+	}
+	if events := g.DrainEvents(); len(events) != 0 {
+		t.Errorf("expected no events from calling off a finished round, got %v", events)
+	}
+	for g.Phase != PREROUND {
+		g.StepGame()
+	}
+	if len(g.Teams) != 2 {
+		t.Errorf("expected a fresh duel after the post-round phase, got %d teams", len(g.Teams))
+	}
+}
+
+func TestSetNextMatchup(t *testing.T) {
+	g := NewTeamBattle(2)
+	names := RosterNames()
+	if err := g.SetNextMatchup(names[:3]); err == nil {
+		t.Error("a duel between teams of two should need four fighters")
+	}
+	if err := g.SetNextMatchup([]string{names[0], names[1], names[2], names[0]}); err == nil {
+		t.Error("a fighter should not be picked twice")
+	}
+	if err := g.SetNextMatchup([]string{names[0], names[1], names[2], "Nobody"}); err == nil {
+		t.Error("unknown fighters should be rejected")
+	}
+
+	picked := []string{names[3], names[2], names[1], names[0]}
+	if err := g.SetNextMatchup(picked); err != nil {
+		t.Fatal(err)
+	}
+	playRound(t, &g)
+	for g.Phase != PREROUND {
+		g.StepGame()
+	}
+	if !slices.Equal(g.Teams[0].Names(), picked[:2]) || !slices.Equal(g.Teams[1].Names(), picked[2:]) {
+		t.Errorf("expected the picked matchup %v, got %v and %v", picked, g.Teams[0].Names(), g.Teams[1].Names())
+	}
+	if g.NextMatchup != nil {
+		t.Error("the picked matchup should only be played once")
+	}
+}
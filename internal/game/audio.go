@@ -17,6 +17,7 @@ type AudioPlayer struct {
 
 func (a *AudioPlayer) Stop() {
 	a.AttackPlaying = false
+	a.AbilityPlaying = ""
 	a.BlockPlaying = false
 	a.DodgePlaying = false
 	a.CritPlaying = false
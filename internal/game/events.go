@@ -0,0 +1,72 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+type LifecycleEventKind uint
+
+const (
+	_                 = iota
+	BETTING_OPENED    // 1
+	BETTING_CLOSED    // 2
+	ROUND_STARTED     // 3
+	FIGHTER_KO        // 4
+	WINNER_DECLARED   // 5
+	CHALLENGER_CHOSEN // 6
+	ROUND_ABORTED     // 7
+)
+
+// Phase transition or notable moment of a round, emitted by StepGame for other subsystems to react to
+type LifecycleEvent struct {
+	Kind  LifecycleEventKind
+	Team  int      // Index of the team the event concerns, -1 for events about the whole arena
+	Names []string // Fighters the event concerns, the knocked out fighter, the winners or the incoming challengers
+}
+
+func (g *GameState) emit(kind LifecycleEventKind, team int, names ...string) {
+	g.Events = append(g.Events, LifecycleEvent{Kind: kind, Team: team, Names: names})
+}
+
+// Take every event emitted since the last call
+func (g *GameState) DrainEvents() []LifecycleEvent {
+	events := g.Events
+	g.Events = nil
+	return events
+}
+
+// Emit a knockout for every fighter that went down since the last check
+func (g *GameState) checkKnockouts() {
+	for teamIdx := range g.Teams {
+		for i := range g.Teams[teamIdx].Members {
+			fighter := &g.Teams[teamIdx].Members[i]
+			if !fighter.Alive() && !fighter.KnockedOut {
+				fighter.KnockedOut = true
+				g.emit(FIGHTER_KO, teamIdx, fighter.Name)
+			}
+		}
+	}
+}
+
+// Short announcement of the event for banners
+func (e LifecycleEvent) String() string {
+	names := strings.Join(e.Names, " & ")
+	switch e.Kind {
+	case BETTING_OPENED:
+		return "Betting is open!"
+	case BETTING_CLOSED:
+		return "Betting is closed"
+	case ROUND_STARTED:
+		return "Fight!"
+	case FIGHTER_KO:
+		return fmt.Sprintf("KO! %s is down", names)
+	case WINNER_DECLARED:
+		return fmt.Sprintf("%s wins!", names)
+	case CHALLENGER_CHOSEN:
+		return fmt.Sprintf("Next up: %s", names)
+	case ROUND_ABORTED:
+		return "Round called off, bets are void"
+	}
+	return ""
+}
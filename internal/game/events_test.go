@@ -0,0 +1,53 @@
+package game
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLifecycleEventOrder(t *testing.T) {
+	g := New()
+	kinds := []LifecycleEventKind{}
+	collect := func() {
+		for _, event := range g.DrainEvents() {
+			kinds = append(kinds, event.Kind)
+		}
+	}
+	playRound(t, &g)
+	collect()
+	if !g.AudioPlayers.WinnerPlaying {
+		t.Error("winner sound should play when the winner is declared")
+	}
+	for g.Phase != PREROUND {
+		g.StepGame()
+	}
+	collect()
+
+	expected := []LifecycleEventKind{BETTING_OPENED, BETTING_CLOSED, ROUND_STARTED, FIGHTER_KO, WINNER_DECLARED, CHALLENGER_CHOSEN}
+	if !slices.Equal(kinds, expected) {
+		t.Errorf("expected events %v, got %v", expected, kinds)
+	}
+	if g.AudioPlayers.WinnerPlaying {
+		t.Error("winner sound should only play on the step the winner is declared")
+	}
+}
+
+func TestChallengersAndCountdown(t *testing.T) {
+	g := New()
+	if !g.Challenging(0) || !g.Challenging(1) {
+		t.Error("every team of a fresh game should be challenging")
+	}
+	g.StepGame()
+	if g.Countdown() != g.PhaseTimer+1 || !g.BettingOpen {
+		t.Errorf("expected an open pre-round counting down, got countdown %d and betting open %t", g.Countdown(), g.BettingOpen)
+	}
+
+	playRound(t, &g)
+	winnerIdx := g.WinningTeam()
+	for g.Phase != PREROUND {
+		g.StepGame()
+	}
+	if g.Challenging(winnerIdx) || !g.Challenging(1-winnerIdx) {
+		t.Errorf("only the team facing winner %d should be challenging, got %v", winnerIdx, g.Challengers)
+	}
+}
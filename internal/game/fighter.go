@@ -3,6 +3,7 @@ package game
 import (
 	"fmt"
 	"math/rand/v2"
+	"slices"
 )
 
 // type FighterState uint
@@ -46,34 +47,63 @@ type Ability struct {
 	Name        string
 	Description string
 	Timer       IntStat
-	InvokeFunc  func(self *Fighter, other *Fighter)
+	Target      AbilityTarget                       // Which fighters InvokeFunc is called on
+	InvokeFunc  func(self *Fighter, other *Fighter) // Called once for every target of the ability
+	Conditions  []AbilityCondition                  // Ready abilities are held until all of these hold
+	Priority    int                                 // Higher priority abilities are used first when several are ready
+	HeldTicks   int                                 // Ticks the ability has been ready without being used
 }
 
 type Fighter struct {
-	Name        string    // Name of framework/library
-	Color       string    // Color of logo
-	Health      IntStat   // Represents how much of an "industry standard" the framework/library is / likelihood to stick around in the future
-	Damage      IntStat   // Represents how consistently useful the framework/library is for common tasks
-	Speed       IntStat   // Represents the overall performance under load and scalability of the framework/library, causes fighter to act sooner
-	Accuracy    FloatStat // Represents how simple the library/frame work is / how easy it is to get it right at first (opposite of footguns), causes less misses
-	Dodge       FloatStat // Represents how quickly the framework can adapt and rebound after a failure
-	CritRate    FloatStat // Represents how suprisingly useful or versatile the framework/library is in niche situations
-	AttackTimer IntStat   // Time before next action of fighter, reduced by speed each turn
-	FighterAnim string    // Current Animation playing for fighter
-	Abilities   []Ability // Abilities which may apply status effects to fighters
-	Effects     []Effect  // Effects which are applied by abilities and tick down over time
+	Name        string        // Name of framework/library
+	Color       string        // Color of logo
+	Health      IntStat       // Represents how much of an "industry standard" the framework/library is / likelihood to stick around in the future
+	Damage      IntStat       // Represents how consistently useful the framework/library is for common tasks
+	Speed       IntStat       // Represents the overall performance under load and scalability of the framework/library, causes fighter to act sooner
+	Accuracy    FloatStat     // Represents how simple the library/frame work is / how easy it is to get it right at first (opposite of footguns), causes less misses
+	Dodge       FloatStat     // Represents how quickly the framework can adapt and rebound after a failure
+	CritRate    FloatStat     // Represents how suprisingly useful or versatile the framework/library is in niche situations
+	AttackTimer IntStat       // Time before next action of fighter, reduced by speed each turn
+	FighterAnim string        // Current Animation playing for fighter
+	Abilities   []Ability     // Abilities which may apply status effects to fighters
+	Effects     []Effect      // Effects which are applied by abilities and tick down over time
+	Rating      Rating        // Elo rating, updated after every round the fighter takes part in
+	Targeting   TargetingRule // How the fighter picks which enemy to attack in team battles
+	AI          FighterAI     // Decides whether to use or hold ready abilities, nil uses them right away
+	KnockedOut  bool          // Set once the fighter's knockout has been announced
 }
 
 func (f *Fighter) Reset() *Fighter {
 	f.FighterAnim = "idle"
+	f.KnockedOut = false
 	f.Health.Value = f.Health.MaxValue
+	f.AttackTimer.Value = f.AttackTimer.MaxValue
 	for i := 0; i < len(f.Abilities); i++ {
 		f.Abilities[i].Timer.Value = f.Abilities[i].Timer.MaxValue
+		f.Abilities[i].HeldTicks = 0
+	}
+	for _, effect := range f.Effects {
+		effect.OnRemove(f)
 	}
 	f.Effects = make([]Effect, 0, 3)
 	return f
 }
 
+// Tick every effect on the fighter, removing those which have run out
+func (f *Fighter) tickEffects() {
+	remaining := f.Effects[:0]
+	for _, effect := range f.Effects {
+		effect.OnTick(f)
+		if effect.GetDuration() > 0 {
+			effect.StepDuration()
+			remaining = append(remaining, effect)
+		} else {
+			effect.OnRemove(f)
+		}
+	}
+	f.Effects = remaining
+}
+
 /* Ability ideas:
 
 React -> Virtual DOM: Increase Speed but reduce damage output slightly, I am inevitable...: Deal damage based on popularity [X]
@@ -108,13 +138,28 @@ var fighterList = [...]Fighter{
 			{
 				Name:        "Old But Not Forgotten",
 				Description: "",
+				Target:      TARGET_ENEMY,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
 					other.Health.Value -= self.Health.MaxValue
 				},
 				Timer: NewIntStat(10),
 			},
+			{
+				Name:        "Still on most of the web",
+				Description: "Too big to ignore, draws every attack for a while",
+				Target:      TARGET_SELF,
+				Conditions:  []AbilityCondition{AllyHealthBelow(0.5)},
+				InvokeFunc: func(self *Fighter, other *Fighter) {
+					taunt := Taunt{NewIntStat(4)}
+					self.Effects = append(self.Effects, &taunt)
+					taunt.OnApply(self)
+				},
+				Timer: NewIntStat(7),
+			},
 		},
-		Effects: make([]Effect, 0, 3),
+		Effects:   make([]Effect, 0, 3),
+		Rating:    NewRating(),
+		Targeting: TARGET_RANDOM,
 	},
 	{
 		Name:        "React",
@@ -131,6 +176,7 @@ var fighterList = [...]Fighter{
 			{
 				Name:        "Virtual DOM",
 				Description: "Slows everything down",
+				Target:      TARGET_ENEMY,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
 					// self.Damage.Value -= 2
 					// self.Speed.Value += 2
@@ -145,6 +191,7 @@ var fighterList = [...]Fighter{
 			{
 				Name:        "I am inevitable...",
 				Description: "Crushes competition mainly due to inertia",
+				Target:      TARGET_SELF,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
 					self.Damage.MaxValue *= 2
 					self.Damage.Value = self.Damage.MaxValue
@@ -152,7 +199,9 @@ var fighterList = [...]Fighter{
 				Timer: NewIntStat(8),
 			},
 		},
-		Effects: make([]Effect, 0, 3),
+		Effects:   make([]Effect, 0, 3),
+		Rating:    NewRating(),
+		Targeting: TARGET_LOWEST_HEALTH,
 	},
 	{
 		Name:        "Vue",
@@ -170,12 +219,18 @@ var fighterList = [...]Fighter{
 				Name:        "Second most loved, btw!",
 				Description: "",
 				Timer:       NewIntStat(5),
+				Target:      TARGET_ALLY,
+				Conditions:  []AbilityCondition{AllyHealthBelow(0.5)},
+				Priority:    2,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
-					self.Health.Value = min(self.Health.Value+10, self.Health.MaxValue)
+					other.Health.Value = min(other.Health.Value+10, other.Health.MaxValue)
 				},
 			},
 		},
-		Effects: make([]Effect, 0, 3),
+		Effects:   make([]Effect, 0, 3),
+		Rating:    NewRating(),
+		Targeting: TARGET_LOWEST_HEALTH,
+		AI:        Tactical{},
 	},
 	{
 		Name:        "Svelte",
@@ -193,12 +248,18 @@ var fighterList = [...]Fighter{
 				Name:        "Most Loved Framework, btw",
 				Description: "",
 				Timer:       NewIntStat(5),
+				Target:      TARGET_ALLY,
+				Conditions:  []AbilityCondition{AllyHealthBelow(0.5)},
+				Priority:    2,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
-					self.Health.Value = min(self.Health.Value+10, self.Health.MaxValue)
+					other.Health.Value = min(other.Health.Value+10, other.Health.MaxValue)
 				},
 			},
 		},
-		Effects: make([]Effect, 0, 3),
+		Effects:   make([]Effect, 0, 3),
+		Rating:    NewRating(),
+		Targeting: TARGET_LOWEST_HEALTH,
+		AI:        Tactical{},
 	},
 	{
 		Name:        "Solid",
@@ -216,12 +277,15 @@ var fighterList = [...]Fighter{
 				Name:        "Go my signals...",
 				Description: "",
 				Timer:       NewIntStat(6),
+				Target:      TARGET_ENEMY,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
 					other.Health.Value -= self.Health.MaxValue
 				},
 			},
 		},
-		Effects: make([]Effect, 0, 3),
+		Effects:   make([]Effect, 0, 3),
+		Rating:    NewRating(),
+		Targeting: TARGET_LOWEST_HEALTH,
 	},
 	{
 		Name:        "HTMX",
@@ -239,20 +303,26 @@ var fighterList = [...]Fighter{
 				Name:        "Web 1.0 Larp",
 				Description: "",
 				Timer:       NewIntStat(10),
+				Target:      TARGET_ALL_ENEMIES,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
 					other.Health.Value -= self.Health.MaxValue
 				},
 			},
 			{
 				Name:        "Out of touch",
-				Description: "",
+				Description: "Finishes off frameworks that are already on their way out",
 				Timer:       NewIntStat(5),
+				Target:      TARGET_ENEMY,
+				Conditions:  []AbilityCondition{EnemyHealthBelow(0.2)},
+				Priority:    3,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
 					other.Health.Value -= self.Health.MaxValue
 				},
 			},
 		},
-		Effects: make([]Effect, 0, 3),
+		Effects:   make([]Effect, 0, 3),
+		Rating:    NewRating(),
+		Targeting: TARGET_LOWEST_HEALTH,
 	},
 	{
 		Name:        "Datastar",
@@ -270,15 +340,36 @@ var fighterList = [...]Fighter{
 				Name:        "Greedy Dev",
 				Description: "",
 				Timer:       NewIntStat(5),
+				Target:      TARGET_SELF,
 				InvokeFunc: func(self *Fighter, other *Fighter) {
 					//
 				},
 			},
 		},
-		Effects: make([]Effect, 0, 3),
+		Effects:   make([]Effect, 0, 3),
+		Rating:    NewRating(),
+		Targeting: TARGET_LOWEST_HEALTH,
 	},
 }
 
+// Logo color of every fighter in the roster keyed by name
+func RosterColors() map[string]string {
+	colors := make(map[string]string, len(fighterList))
+	for _, fighter := range fighterList {
+		colors[fighter.Name] = fighter.Color
+	}
+	return colors
+}
+
+// Names of every fighter in the roster
+func RosterNames() []string {
+	names := make([]string, 0, len(fighterList))
+	for _, fighter := range fighterList {
+		names = append(names, fighter.Name)
+	}
+	return names
+}
+
 func chooseRandomFighter() Fighter {
 	randomIndex := rand.IntN(len(fighterList))
 	randomFighter := fighterList[randomIndex]
@@ -289,26 +380,60 @@ func chooseReact() Fighter {
 	return fighterList[1]
 }
 
-func chooseRandomFighterExclusive(excludedFighterName string) (Fighter, error) {
-	swapIndex := -1
-	for i, fighter := range fighterList {
-		if fighter.Name == excludedFighterName {
-			swapIndex = i
-			break
+func chooseFighterByName(name string) (Fighter, error) {
+	for _, fighter := range fighterList {
+		if fighter.Name == name {
+			fighter.Abilities = slices.Clone(fighter.Abilities)
+			fighter.Effects = make([]Effect, 0, 3)
+			return fighter, nil
 		}
 	}
-	if swapIndex == -1 {
-		return Fighter{}, fmt.Errorf("error: Fighter name %s not found", excludedFighterName)
+	return Fighter{}, fmt.Errorf("error: Fighter name %s not found", name)
+}
+
+// Copies of every fighter in the roster except the excluded ones
+func rosterExcluding(excludedFighterNames ...string) []Fighter {
+	fighters := make([]Fighter, 0, len(fighterList))
+	for _, fighter := range fighterList {
+		if slices.Contains(excludedFighterNames, fighter.Name) {
+			continue
+		}
+		fighter.Abilities = slices.Clone(fighter.Abilities)
+		fighter.Effects = make([]Effect, 0, 3)
+		fighters = append(fighters, fighter)
 	}
-	// Swap excluded fighter with first index
-	temp := fighterList[0]
-	fighterList[0] = fighterList[swapIndex]
-	fighterList[swapIndex] = temp
+	return fighters
+}
 
-	randomIndex := 1 + rand.IntN(len(fighterList)-1) // Choose from [1,1-len)
-	randomFighter := fighterList[randomIndex]
-	// log.Printf("Randomly chose %v, excluding %s\n", randomFighter, excludedFighterName)
-	return randomFighter, nil
+func chooseRandomFighterExclusive(excludedFighterName string) (Fighter, error) {
+	return chooseChallenger(UniformRandom{}, Fighter{Name: excludedFighterName})
+}
+
+func chooseChallenger(matchmaker Matchmaker, champion Fighter) (Fighter, error) {
+	challengers, err := chooseChallengers(matchmaker, []Fighter{champion}, 1)
+	if err != nil {
+		return Fighter{}, err
+	}
+	return challengers[0], nil
+}
+
+// Draw a team of challengers for the champions one at a time, the matchmaker compares each against the lead champion
+func chooseChallengers(matchmaker Matchmaker, champions []Fighter, count int) ([]Fighter, error) {
+	excluded := make([]string, 0, len(champions)+count)
+	for _, champion := range champions {
+		excluded = append(excluded, champion.Name)
+	}
+	challengers := make([]Fighter, 0, count)
+	for range count {
+		pool := rosterExcluding(excluded...)
+		if len(pool) == 0 {
+			return nil, fmt.Errorf("error: not enough fighters in the roster to draw %d challengers", count)
+		}
+		challenger := matchmaker.NextChallenger(champions[0], pool)
+		challengers = append(challengers, challenger)
+		excluded = append(excluded, challenger.Name)
+	}
+	return challengers, nil
 }
 
 // Effects to apply from abilities to self or an opponent fighter
@@ -347,3 +472,26 @@ func (s *Slow) OnTick(f *Fighter) {
 func (s *Slow) OnRemove(f *Fighter) {
 	f.Speed.Value = s.LastSpeed
 }
+
+// Forces enemies to attack the taunting fighter while it lasts
+type Taunt struct {
+	duration IntStat
+}
+
+func (t *Taunt) StepDuration() {
+	t.duration.Value -= 1
+}
+
+func (t *Taunt) GetDuration() int {
+	return t.duration.Value
+}
+
+func (t *Taunt) OnApply(f *Fighter) {
+	// Targeting checks for the effect, nothing to change on the fighter
+}
+func (t *Taunt) OnTick(f *Fighter) {
+	// Do nothing
+}
+func (t *Taunt) OnRemove(f *Fighter) {
+	// Do nothing
+}
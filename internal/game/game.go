@@ -4,63 +4,219 @@ import (
 	"fmt"
 	"js-bet/internal/eventlog"
 	"log"
+	"log/slog"
 	"math/rand/v2"
-	// "slices"
+	"slices"
+	"strings"
 )
 
 // Statistical Consts
 const CRIT_MULTIPLIER = 2.0
 
 type GameState struct {
-	Fighters     [2]Fighter
+	Teams        []Team // Sides of the battle, left and right in a duel or one per fighter in a battle royale
+	TeamSize     int    // Number of fighters on each side
+	Placements   []int  // Indices of teams in the order they were knocked out, the winner is added last
 	FrameCount   int
 	AudioPlayers AudioPlayer
 	Winner       WinnerEnum
 	Phase        GamePhase
 	PhaseTimer   int // Timer for pre-round and post-round phases (Not an IntValue since each has its own duration)
 	Status       string
+	Tournament   *Tournament // Bracket being played, nil outside of tournament mode
+	Matchmaker   Matchmaker  // Picks the challenger for the winner of each round outside of tournament mode
+	BettingOpen  bool        // Bets are taken from the start of the pre-round phase until the round starts
+	Challengers  []int       // Indices of the teams newly drawn for the upcoming round, nil when every team is new
+	Round        int         // Rounds started since the arena opened, identifies the current round in logs
+	RoundTicks   int         // Ticks played in the current round, it is decided on health at ROUND_TICK_LIMIT
+	NextMatchup  []string    // Fighters picked by an admin for the next round, drawn as usual when nil
+	Events       []LifecycleEvent
 }
 
+/*
+Ticks after which a round still going is decided on health, about five minutes at one tick a second
+
+Fighters left alone with healing abilities can otherwise trade heals for hours with betting stuck closed
+*/
+const ROUND_TICK_LIMIT = 300
+
 type UserState struct {
 	Gold int
 }
 
 func New() GameState {
+	return NewTeamBattle(1)
+}
 
-	fighters := [2]Fighter{}
-	fighters[0] = chooseReact()
-	rightFighter, err := chooseRandomFighterExclusive(fighters[0].Name)
+// Start a game where each side fields a team of teamSize fighters, React always starts on the left
+func NewTeamBattle(teamSize int) GameState {
+	react := chooseReact()
+	leftTeam, err := chooseChallengers(UniformRandom{}, []Fighter{react}, teamSize-1)
 	if err != nil {
 		log.Panic(err)
 	}
-	fighters[1] = rightFighter
+	leftTeam = append([]Fighter{react}, leftTeam...)
+	rightTeam, err := chooseChallengers(UniformRandom{}, leftTeam, teamSize)
+	if err != nil {
+		log.Panic(err)
+	}
+	return GameState{
+		Teams:      []Team{NewTeam(leftTeam...), NewTeam(rightTeam...)},
+		TeamSize:   teamSize,
+		Winner:     NEITHER,
+		Phase:      PREROUND,
+		PhaseTimer: 10,
+		Matchmaker: UniformRandom{},
+	}
+}
+
+// Start a game which plays through the matches of a tournament bracket in order
+func NewTournamentGame(t *Tournament) (GameState, error) {
+	g := GameState{
+		TeamSize:   1,
+		Winner:     NEITHER,
+		Phase:      PREROUND,
+		PhaseTimer: 10,
+		Tournament: t,
+	}
+	err := g.loadTournamentMatch()
+	return g, err
+}
+
+// Place the fighters of the current tournament match into the arena
+func (g *GameState) loadTournamentMatch() error {
+	match := g.Tournament.CurrentMatch()
+	if match == nil {
+		return fmt.Errorf("error: tournament has no match to load")
+	}
+	left, err := chooseFighterByName(match.Left)
+	if err != nil {
+		return err
+	}
+	right, err := chooseFighterByName(match.Right)
+	if err != nil {
+		return err
+	}
+	g.Teams = []Team{NewTeam(left), NewTeam(right)}
+	return nil
+}
+
+// Move on to the next match of the bracket, seeding a fresh tournament once a champion is crowned
+func (g *GameState) nextTournamentMatch() {
+	if g.Tournament.Finished() {
+		g.Tournament = NewTournament(g.Tournament.Format)
+	}
+	if err := g.loadTournamentMatch(); err != nil {
+		slog.Error("Unable to load tournament match, falling back to duels", "err", err)
+		round := g.Round
+		*g = New()
+		g.Round = round
+	}
+}
+
+// Start a free-for-all between size fighters, each fighting on its own
+func NewBattleRoyale(size int) GameState {
+	react := chooseReact()
+	field, err := chooseChallengers(UniformRandom{}, []Fighter{react}, size-1)
+	if err != nil {
+		log.Panic(err)
+	}
+	teams := []Team{NewTeam(react)}
+	for _, fighter := range field {
+		teams = append(teams, NewTeam(fighter))
+	}
 	return GameState{
-		Fighters:   fighters,
+		Teams:      teams,
+		TeamSize:   1,
 		Winner:     NEITHER,
 		Phase:      PREROUND,
 		PhaseTimer: 10,
+		Matchmaker: UniformRandom{},
 	}
 }
 
+// Battles with more than two sides are fought as a battle royale
+func (g GameState) FreeForAll() bool {
+	return len(g.Teams) > 2
+}
+
+// Every fighter on every side of the battle
+func (g GameState) AllFighters() []Fighter {
+	fighters := []Fighter{}
+	for _, team := range g.Teams {
+		fighters = append(fighters, team.Members...)
+	}
+	return fighters
+}
+
+// Names of the fighters on every side of the battle
+func (g GameState) TeamNames() [][]string {
+	names := make([][]string, 0, len(g.Teams))
+	for _, team := range g.Teams {
+		names = append(names, team.Names())
+	}
+	return names
+}
+
+// Index of the team left standing, -1 while the round is still being fought
+func (g GameState) WinningTeam() int {
+	if len(g.Placements) < len(g.Teams) {
+		return -1
+	}
+	return g.Placements[len(g.Placements)-1]
+}
+
+// Finishing position of a team, 1 for the winner and 0 while the team is still standing
+func (g GameState) Placement(teamIdx int) int {
+	for i, placed := range g.Placements {
+		if placed == teamIdx {
+			return len(g.Teams) - i
+		}
+	}
+	return 0
+}
+
 func (g *GameState) ResetKeepWinner() {
-	switch g.Winner {
-	case LEFT:
-		g.Fighters[0].Reset()
-		newRight, err := chooseRandomFighterExclusive(g.Fighters[0].Name)
-		if err != nil {
-			return
+	if g.Tournament != nil {
+		g.nextTournamentMatch()
+		return
+	}
+	if g.NextMatchup != nil {
+		g.loadNextMatchup()
+		return
+	}
+	winnerIdx := g.WinningTeam()
+	g.Placements = nil
+	if winnerIdx == -1 {
+		matchmaker, round := g.Matchmaker, g.Round
+		if g.FreeForAll() {
+			*g = NewBattleRoyale(len(g.Teams))
+		} else {
+			*g = NewTeamBattle(g.TeamSize)
 		}
-		g.Fighters[1] = newRight
-	case RIGHT:
-		g.Fighters[1].Reset()
-		newLeft, err := chooseRandomFighterExclusive(g.Fighters[1].Name)
+		g.Matchmaker, g.Round = matchmaker, round
+		return
+	}
+	g.Teams[winnerIdx].Reset()
+	champions := g.Teams[winnerIdx].Members
+	if g.FreeForAll() {
+		// Keep the winner and draw a fresh field around it
+		challengers, err := chooseChallengers(g.Matchmaker, champions, len(g.Teams)-1)
 		if err != nil {
 			return
 		}
-		g.Fighters[0] = newLeft
-	default:
-		*g = New()
+		teams := []Team{g.Teams[winnerIdx]}
+		for _, challenger := range challengers {
+			teams = append(teams, NewTeam(challenger))
+		}
+		g.Teams = teams
+		return
+	}
+	challengers, err := chooseChallengers(g.Matchmaker, champions, g.TeamSize)
+	if err != nil {
+		return
 	}
+	g.Teams[1-winnerIdx] = NewTeam(challengers...)
 }
 
 type WinnerEnum uint
@@ -72,6 +228,16 @@ const (
 	NEITHER // 3
 )
 
+func (w WinnerEnum) String() string {
+	switch w {
+	case LEFT:
+		return "left"
+	case RIGHT:
+		return "right"
+	}
+	return ""
+}
+
 type GamePhase uint
 
 const (
@@ -81,44 +247,66 @@ const (
 	POSTROUND
 )
 
-func (g *GameState) Act(order ActingOrder) {
-	fighterIdx := 0
-	oppIdx := 1
-	switch order {
-	case RIGHTTOLEFT:
-		fighterIdx = 1
-		oppIdx = 0
+func (p GamePhase) String() string {
+	switch p {
+	case PREROUND:
+		return "preround"
+	case ROUND:
+		return "round"
+	case POSTROUND:
+		return "postround"
+	}
+	return ""
+}
+
+// Living fighters on every team other than the given one
+func (g *GameState) enemiesOf(teamIdx int) []*Fighter {
+	enemies := []*Fighter{}
+	for i := range g.Teams {
+		if i != teamIdx {
+			enemies = append(enemies, g.Teams[i].living()...)
+		}
+	}
+	return enemies
+}
+
+// The next actor of the given team attacks one of its enemies
+func (g *GameState) Act(teamIdx int) {
+	fighter := &g.Teams[teamIdx].Members[g.Teams[teamIdx].nextActor()]
+	target := fighter.chooseTarget(g.enemiesOf(teamIdx))
+	if target == nil {
+		return
 	}
 	// Reset actor's attack timer to its maximum
-	g.Fighters[fighterIdx].AttackTimer.Value = g.Fighters[fighterIdx].AttackTimer.MaxValue // Reset timer
+	fighter.AttackTimer.Value = fighter.AttackTimer.MaxValue // Reset timer
 	// Determine if hit was confirmed
-	hit := g.Fighters[fighterIdx].CheckHit(0.0)
-	damage := g.Fighters[fighterIdx].Damage.Value
-	g.Fighters[fighterIdx].FighterAnim = "attack"
+	hit := fighter.CheckHit(0.0)
+	damage := fighter.Damage.Value
+	fighter.FighterAnim = "attack"
 	if !hit {
 		g.AudioPlayers.DodgePlaying = true
-		eventlog.EventLog.Write(fmt.Sprintf("%s just missed...", g.Fighters[fighterIdx].Name))
+		eventlog.EventLog.Write(fmt.Sprintf("%s just missed...", fighter.Name))
 		// Add message to the status bar
-		g.Status = fmt.Sprintf("%s attacked %s and missed!", g.Fighters[fighterIdx].Name, g.Fighters[oppIdx].Name)
+		g.Status = fmt.Sprintf("%s attacked %s and missed!", fighter.Name, target.Name)
 		return
 	} else {
 		g.AudioPlayers.AttackPlaying = true
 		// Add message to the status bar
-		g.Status = fmt.Sprintf("%s attacked %s", g.Fighters[fighterIdx].Name, g.Fighters[oppIdx].Name)
+		g.Status = fmt.Sprintf("%s attacked %s", fighter.Name, target.Name)
 	}
-	g.Fighters[oppIdx].FighterAnim = "defend"
+	target.FighterAnim = "defend"
 	g.AudioPlayers.BlockPlaying = true
-	crit := g.Fighters[fighterIdx].CheckCrit()
+	crit := fighter.CheckCrit()
 	if crit {
 		g.AudioPlayers.AttackPlaying = false
 		g.AudioPlayers.CritPlaying = true
 		damage *= 2.0
-		g.Fighters[fighterIdx].FighterAnim = "crit"
-		eventlog.EventLog.Write(fmt.Sprintf("%s just critically hit %s for %d", g.Fighters[fighterIdx].Name, g.Fighters[oppIdx].Name, damage))
+		fighter.FighterAnim = "crit"
+		eventlog.EventLog.Write(fmt.Sprintf("%s just critically hit %s for %d", fighter.Name, target.Name, damage))
 	} else {
-		eventlog.EventLog.Write(fmt.Sprintf("%s just hit %s for %d", g.Fighters[fighterIdx].Name, g.Fighters[oppIdx].Name, damage))
+		eventlog.EventLog.Write(fmt.Sprintf("%s just hit %s for %d", fighter.Name, target.Name, damage))
 	}
-	g.Fighters[oppIdx].Health.Value -= damage
+	target.Health.Value -= damage
 }
 
 func (f Fighter) CheckHit(dodgeRate float32) bool {
@@ -135,51 +323,100 @@ func (f Fighter) CheckCrit() bool {
 	return false
 }
 
-// TODO add sound for winner being determined
-func (g *GameState) determineWinner() WinnerEnum {
-	left := g.Fighters[0]
-	right := g.Fighters[1]
-	if left.Health.Value <= 0 && right.Health.Value <= 0 {
-		if left.Health.Value < right.Health.Value {
-			return RIGHT
-		} else {
-			return LEFT
+/*
+Record every team knocked out since the last step, returns true once a single team is left standing
+
+Teams knocked out on the same step are placed by their remaining health, and if every team is down
+the team with the most health left wins. Once the time is up every team still standing is placed the same way
+*/
+func (g *GameState) recordEliminations(timeUp bool) bool {
+	knockedOut := []int{}
+	for i, team := range g.Teams {
+		if (timeUp || team.Defeated()) && !slices.Contains(g.Placements, i) {
+			knockedOut = append(knockedOut, i)
+		}
+	}
+	// Lowest health is placed first, later teams are placed first on ties so the left side wins a draw
+	slices.SortStableFunc(knockedOut, func(a int, b int) int {
+		if diff := g.Teams[a].TotalHealth() - g.Teams[b].TotalHealth(); diff != 0 {
+			return diff
+		}
+		return b - a
+	})
+	g.Placements = append(g.Placements, knockedOut...)
+
+	standing := []int{}
+	for i := range g.Teams {
+		if !slices.Contains(g.Placements, i) {
+			standing = append(standing, i)
 		}
-	} else if left.Health.Value <= 0 {
-		return RIGHT
-	} else if right.Health.Value <= 0 {
-		return LEFT
 	}
-	return NEITHER
+	switch len(standing) {
+	case 0:
+		return true
+	case 1:
+		g.Placements = append(g.Placements, standing[0])
+		return true
+	}
+	return false
 }
 
-func useAbility(abilityIdx int, self *Fighter, other *Fighter, gs *GameState) {
+func useAbility(abilityIdx int, self *Fighter, allies *Team, enemies []*Fighter, gs *GameState) {
 	ability := self.Abilities[abilityIdx]
-	ability.InvokeFunc(self, other)
+	for _, target := range abilityTargets(ability, self, allies, enemies) {
+		ability.InvokeFunc(self, target)
+	}
 	eventlog.EventLog.Write(fmt.Sprintf("%s used '%s' ", self.Name, ability.Name))
 	self.Abilities[abilityIdx].Timer.Value = ability.Timer.MaxValue
-	self.FighterAnim = "ability"
+	self.Abilities[abilityIdx].HeldTicks = 0
+	self.FighterAnim = "ability-" + ability.Cue()
+	gs.AudioPlayers.AbilityPlaying = ability.Cue()
 	gs.Status = fmt.Sprintf("%s used '%s'", self.Name, ability.Name)
 }
 
+// A fighter on a team with an ability ready to use
+type readyAbility struct {
+	memberIdx  int
+	abilityIdx int
+}
+
+func (r readyAbility) ready() bool {
+	return r.abilityIdx != -1
+}
+
 func (g *GameState) StepGame() {
+	g.AudioPlayers.Stop()
 	switch g.Phase {
 	case PREROUND:
+		if !g.BettingOpen {
+			g.BettingOpen = true
+			g.emit(BETTING_OPENED, -1)
+		}
 		g.PhaseTimer -= 1
 		g.Status = "Pre-Round Phase"
 		if g.PhaseTimer < 0 {
 			g.Phase = ROUND
 			g.Status = "Round start!"
+			g.BettingOpen = false
+			g.Round += 1
+			g.RoundTicks = 0
+			g.emit(BETTING_CLOSED, -1)
+			g.emit(ROUND_STARTED, -1)
 		}
 		return
 	case POSTROUND:
 		g.PhaseTimer -= 1
 		if g.PhaseTimer < 0 {
+			kept := []string{}
+			if winnerIdx := g.WinningTeam(); winnerIdx != -1 && g.Tournament == nil {
+				kept = g.Teams[winnerIdx].Names()
+			}
 			g.Phase = PREROUND
 			g.ResetKeepWinner()
 			g.PhaseTimer = 10
 			g.Status = "Pre-Round Phase"
 			g.Winner = NEITHER
+			g.emitChallengers(kept)
 		}
 		return
 	default: // Skip if middle of round or any other case
@@ -187,130 +424,194 @@ func (g *GameState) StepGame() {
 	}
 
 	g.FrameCount += 1
-	g.Fighters[0].FighterAnim = "idle"
-	g.Fighters[1].FighterAnim = "idle"
-	g.AudioPlayers.Stop()
+	g.RoundTicks += 1
+	for teamIdx := range g.Teams {
+		for i := range g.Teams[teamIdx].Members {
+			g.Teams[teamIdx].Members[i].FighterAnim = "idle"
+		}
+	}
+	g.checkKnockouts()
 
-	// Check for a non-positive health, choose a winner and keep them in the game for the next round
-	var winner = g.determineWinner()
-	if winner != NEITHER {
-		g.Winner = winner
+	// Check for a defeated side, choose a winner and keep them in the game for the next round
+	timeUp := g.RoundTicks >= ROUND_TICK_LIMIT
+	if g.recordEliminations(timeUp) {
+		winnerIdx := g.WinningTeam()
+		g.AudioPlayers.WinnerPlaying = true
 		g.Phase = POSTROUND
 		g.PhaseTimer = 10
-		var winnerName string
-		switch g.Winner {
-		case LEFT:
-			winnerName = g.Fighters[0].Name
-		case RIGHT:
-			winnerName = g.Fighters[1].Name
+		if !g.FreeForAll() {
+			g.Winner = LEFT
+			if winnerIdx == 1 {
+				g.Winner = RIGHT
+			}
 		}
+		winnerName := strings.Join(g.Teams[winnerIdx].Names(), " & ")
+		g.updateRatings()
 		g.Status = fmt.Sprintf("Winner is: %s", winnerName)
+		if timeUp {
+			g.Status = fmt.Sprintf("Time is up, %s wins on health", winnerName)
+		}
+		if g.Tournament != nil {
+			if err := g.Tournament.RecordResult(winnerName); err != nil {
+				slog.Error("Unable to record tournament result", "tournament_id", g.Tournament.ID, "err", err)
+			} else if g.Tournament.Finished() {
+				g.Status = fmt.Sprintf("%s is the tournament champion!", winnerName)
+			}
+		}
+		g.emit(WINNER_DECLARED, winnerIdx, g.Teams[winnerIdx].Names()...)
 		return
 	}
 
-	// Check if an ability is ready on each fighter to see if they should use it, prioritize ability usage over attacks
-	usedAbilityIdxs := [2]int{-1, -1}
+	// Check if an ability is ready on each side to see if it should be used, prioritize ability usage over attacks
+	usedAbilities := make([]readyAbility, len(g.Teams))
 
-	// For each fighter...
-	for fIdx := 0; fIdx < 2; fIdx += 1 {
-		// Update all effect durations on each fighter
-		log.Printf("%d -> %v", fIdx, g.Fighters[fIdx].Effects)
-		for i, effect := range g.Fighters[fIdx].Effects {
-			// Reduce effect duration if > 0
-			if effect.GetDuration() > 0 {
-				effect.StepDuration()
-			} else {
-				g.Fighters[fIdx].Effects = append(g.Fighters[fIdx].Effects[:i], g.Fighters[fIdx].Effects[i+1:]...)
+	// For each living fighter on each side...
+	for teamIdx := range g.Teams {
+		usedAbilities[teamIdx] = readyAbility{-1, -1}
+		for mIdx := range g.Teams[teamIdx].Members {
+			fighter := &g.Teams[teamIdx].Members[mIdx]
+			if !fighter.Alive() {
+				continue
 			}
-			// Apply tick function on each fighter
-			effect.OnTick(&g.Fighters[0])
-		}
-		// Update all ability timers on each fighter
-		for i := 0; i < len(g.Fighters[fIdx].Abilities); i++ {
-			ability := g.Fighters[fIdx].Abilities[i]
-			g.Fighters[fIdx].Abilities[i].Timer.Value -= 1
-			if ability.Timer.Value <= 0 {
-				usedAbilityIdxs[fIdx] = i
+			// Update all effect durations on each fighter
+			slog.Debug("Ticking effects", "fighter", fighter.Name, "effects", fighter.Effects)
+			fighter.tickEffects()
+			// Update all ability timers on each fighter, ready abilities stay at zero and are held until the fighter wants to use them
+			ctx := AbilityContext{Self: fighter, Allies: &g.Teams[teamIdx], Enemies: g.enemiesOf(teamIdx)}
+			for i := 0; i < len(fighter.Abilities); i++ {
+				ability := &fighter.Abilities[i]
+				if ability.Timer.Value > 0 {
+					ability.Timer.Value = max(ability.Timer.Value-1, 0)
+					continue
+				}
+				ability.HeldTicks += 1
+				if !fighter.wantsAbility(*ability, ctx) {
+					continue
+				}
+				best := usedAbilities[teamIdx]
+				if !best.ready() || fighter.Abilities[i].preferredOver(g.Teams[teamIdx].Members[best.memberIdx].Abilities[best.abilityIdx]) {
+					usedAbilities[teamIdx] = readyAbility{mIdx, i}
+				}
 			}
 		}
 	}
-	var leftAbilityIdx int = usedAbilityIdxs[0]
-	var rightAbilityIdx int = usedAbilityIdxs[1]
-
-	// Prioritize using an ability first and then exiting
-	if leftAbilityIdx != -1 || rightAbilityIdx != -1 {
-		if leftAbilityIdx != -1 && rightAbilityIdx != -1 {
-			leftAbility := g.Fighters[0].Abilities[leftAbilityIdx]
-			rightAbility := g.Fighters[1].Abilities[rightAbilityIdx]
-			if leftAbility.Timer.Value < rightAbility.Timer.Value {
-				useAbility(leftAbilityIdx, &g.Fighters[0], &g.Fighters[1], g)
-			} else if leftAbility.Timer.Value > rightAbility.Timer.Value {
-				useAbility(rightAbilityIdx, &g.Fighters[1], &g.Fighters[0], g)
-			} else {
-				rand := rand.Float32() // Choose randomly on second tie
-				if rand < 0.5 {        // Left fighter acts
-					useAbility(leftAbilityIdx, &g.Fighters[0], &g.Fighters[1], g)
-				} else { // Right fighter acts
-					useAbility(rightAbilityIdx, &g.Fighters[1], &g.Fighters[0], g)
-				}
+
+	// Prioritize using an ability first and then exiting, the most overdue ability goes first and ties are chosen randomly
+	abilityTeam := -1
+	ties := 0
+	for teamIdx, used := range usedAbilities {
+		if !used.ready() {
+			continue
+		}
+		if abilityTeam == -1 {
+			abilityTeam = teamIdx
+			ties = 1
+			continue
+		}
+		held := g.Teams[teamIdx].Members[used.memberIdx].Abilities[used.abilityIdx].HeldTicks
+		best := usedAbilities[abilityTeam]
+		bestHeld := g.Teams[abilityTeam].Members[best.memberIdx].Abilities[best.abilityIdx].HeldTicks
+		if held > bestHeld {
+			abilityTeam = teamIdx
+			ties = 1
+		} else if held == bestHeld {
+			ties += 1
+			if rand.IntN(ties) == 0 {
+				abilityTeam = teamIdx
 			}
-		} else if leftAbilityIdx != -1 {
-			useAbility(leftAbilityIdx, &g.Fighters[0], &g.Fighters[1], g)
-		} else { // Right ability is not nil
-			useAbility(rightAbilityIdx, &g.Fighters[1], &g.Fighters[0], g)
 		}
+	}
+	if abilityTeam != -1 {
+		used := usedAbilities[abilityTeam]
+		useAbility(used.abilityIdx, &g.Teams[abilityTeam].Members[used.memberIdx], &g.Teams[abilityTeam], g.enemiesOf(abilityTeam), g)
 		return // Return regardless to not double dip on abilities and attacks
 	}
 
-	// Step forward each fighter's attack timer
-	g.Fighters[0].AttackTimer.Value -= g.Fighters[0].Speed.Value
-	g.Fighters[1].AttackTimer.Value -= g.Fighters[1].Speed.Value
+	// Step forward each living fighter's attack timer
+	for teamIdx := range g.Teams {
+		for i := range g.Teams[teamIdx].Members {
+			fighter := &g.Teams[teamIdx].Members[i]
+			if fighter.Alive() {
+				fighter.AttackTimer.Value -= fighter.Speed.Value
+			}
+		}
+	}
 
-	order := g.determineActingOrder()
-	switch order {
-	case NOT_READY:
-		break
-	default:
-		g.Act(order)
+	actingTeam := g.determineActingTeam()
+	if actingTeam != NOT_READY {
+		g.Act(actingTeam)
+	}
+}
+
+// Announce every team drawn for the next round, except the winners that kept their place
+func (g *GameState) emitChallengers(kept []string) {
+	g.Challengers = []int{}
+	for teamIdx, team := range g.Teams {
+		if !slices.Equal(team.Names(), kept) {
+			g.Challengers = append(g.Challengers, teamIdx)
+			g.emit(CHALLENGER_CHOSEN, teamIdx, team.Names()...)
+		}
 	}
 }
 
-type ActingOrder uint
+// Whether the team was newly drawn for the upcoming round rather than kept from the last one
+func (g GameState) Challenging(teamIdx int) bool {
+	return g.Challengers == nil || slices.Contains(g.Challengers, teamIdx)
+}
 
-const (
-	_ = iota
-	LEFTTORIGHT
-	RIGHTTOLEFT
-	NOT_READY
-)
+// Whole seconds left before the pre-round or post-round phase ends, the game is stepped once per second
+func (g GameState) Countdown() int {
+	if g.Phase != PREROUND && g.Phase != POSTROUND {
+		return 0
+	}
+	return g.PhaseTimer + 1
+}
 
-func (g *GameState) determineActingOrder() ActingOrder {
-	lReady := g.Fighters[0].AttackTimer.Value <= 0
-	rReady := g.Fighters[1].AttackTimer.Value <= 0
-
-	if lReady && rReady {
-		if g.Fighters[0].AttackTimer.Value == g.Fighters[1].AttackTimer.Value { // Choose lesser AttackTimer when both ready, higher speed on ties
-			if g.Fighters[0].Speed.Value == g.Fighters[1].Speed.Value {
-				rand := rand.Float32() // Choose randomly on second tie
-				if rand < 0.5 {        // Left fighter acts
-					return LEFTTORIGHT
-				} else { // Right fighter acts
-					return RIGHTTOLEFT
-				}
-			} else if g.Fighters[0].Speed.Value > g.Fighters[1].Speed.Value {
-				return LEFTTORIGHT
-			} else {
-				return RIGHTTOLEFT
+// Returned by determineActingTeam when no fighter is ready to attack
+const NOT_READY = -1
+
+/*
+Compare the next actor of each side, the side whose actor is ready first gets to attack
+
+Lesser AttackTimer acts first when several are ready, higher speed on ties and randomly on a second tie
+*/
+func (g *GameState) determineActingTeam() int {
+	actingTeam := NOT_READY
+	var actor Fighter
+	ties := 0
+	for teamIdx, team := range g.Teams {
+		memberIdx := team.nextActor()
+		if memberIdx == -1 || team.Members[memberIdx].AttackTimer.Value > 0 {
+			continue
+		}
+		candidate := team.Members[memberIdx]
+		if actingTeam == NOT_READY ||
+			candidate.AttackTimer.Value < actor.AttackTimer.Value ||
+			(candidate.AttackTimer.Value == actor.AttackTimer.Value && candidate.Speed.Value > actor.Speed.Value) {
+			actingTeam = teamIdx
+			actor = candidate
+			ties = 1
+		} else if candidate.AttackTimer.Value == actor.AttackTimer.Value && candidate.Speed.Value == actor.Speed.Value {
+			ties += 1
+			if rand.IntN(ties) == 0 { // Choose randomly on second tie
+				actingTeam = teamIdx
+				actor = candidate
 			}
-		} else if g.Fighters[0].AttackTimer.Value < g.Fighters[1].AttackTimer.Value {
-			return LEFTTORIGHT
-		} else { // g.Fighters[1].AttackTimer < g.Fighters[0].AttackTimer
-			return RIGHTTOLEFT
 		}
-	} else if lReady {
-		return LEFTTORIGHT
-	} else if rReady {
-		return RIGHTTOLEFT
 	}
-	return NOT_READY // Reduntant return
+	return actingTeam
+}
+
+// Rate the result of the round, duels as a match between both sides and battle royales by finishing position
+func (g *GameState) updateRatings() {
+	winnerIdx := g.WinningTeam()
+	if g.FreeForAll() {
+		ranked := make([]*Fighter, 0, len(g.Teams))
+		for i := len(g.Placements) - 1; i >= 0; i-- {
+			ranked = append(ranked, &g.Teams[g.Placements[i]].Members[0])
+		}
+		UpdatePlacementRatings(ranked)
+		return
+	}
+	UpdateTeamRatings(&g.Teams[winnerIdx], &g.Teams[1-winnerIdx])
 }
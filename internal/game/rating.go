@@ -0,0 +1,185 @@
+package game
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Elo rating constants
+const DEFAULT_RATING = 1500
+const ELO_K_FACTOR = 32
+
+type Rating struct {
+	Value  int
+	Change int // Rating gained or lost in the fighter's most recent match
+}
+
+func NewRating() Rating {
+	return Rating{Value: DEFAULT_RATING}
+}
+
+// Probability of a fighter with the given rating beating the opponent
+func expectedScore(rating int, opponent int) float64 {
+	return 1.0 / (1.0 + math.Pow(10, float64(opponent-rating)/400.0))
+}
+
+// Move rating points from the loser to the winner, scaled by how unexpected the result was
+func UpdateRatings(winner *Fighter, loser *Fighter) {
+	winners := NewTeam(*winner)
+	losers := NewTeam(*loser)
+	UpdateTeamRatings(&winners, &losers)
+	*winner = winners.Members[0]
+	*loser = losers.Members[0]
+}
+
+// Rate a team battle as a match between the average ratings of each side, every member gains or loses the same amount
+func UpdateTeamRatings(winners *Team, losers *Team) {
+	change := int(math.Round(ELO_K_FACTOR * (1.0 - expectedScore(winners.AverageRating(), losers.AverageRating()))))
+	for i := range winners.Members {
+		winners.Members[i].Rating.Value += change
+		winners.Members[i].Rating.Change = change
+		setRosterRating(winners.Members[i].Name, winners.Members[i].Rating)
+	}
+	for i := range losers.Members {
+		losers.Members[i].Rating.Value -= change
+		losers.Members[i].Rating.Change = -change
+		setRosterRating(losers.Members[i].Name, losers.Members[i].Rating)
+	}
+}
+
+/*
+Rate a battle royale as a match between every pair of fighters, the better placed fighter winning each one
+
+Fighters are given best placed first and the K factor is split across the opponents of each fighter
+*/
+func UpdatePlacementRatings(ranked []*Fighter) {
+	if len(ranked) < 2 {
+		return
+	}
+	k := float64(ELO_K_FACTOR) / float64(len(ranked)-1)
+	changes := make([]float64, len(ranked))
+	for i := range ranked {
+		for j := i + 1; j < len(ranked); j++ {
+			change := k * (1.0 - expectedScore(ranked[i].Rating.Value, ranked[j].Rating.Value))
+			changes[i] += change
+			changes[j] -= change
+		}
+	}
+	for i, fighter := range ranked {
+		fighter.Rating.Change = int(math.Round(changes[i]))
+		fighter.Rating.Value += fighter.Rating.Change
+		setRosterRating(fighter.Name, fighter.Rating)
+	}
+}
+
+/*
+Chance of each team winning the battle according to the average ratings of their members
+
+Each team's strength is 10^(rating/400) and it wins in proportion to its share of the total strength, which
+matches the Elo expected score for two teams and extends it to a battle royale
+*/
+func (g GameState) WinChances() []float64 {
+	chances := make([]float64, len(g.Teams))
+	total := 0.0
+	for i, team := range g.Teams {
+		chances[i] = math.Pow(10, float64(team.AverageRating())/400.0)
+		total += chances[i]
+	}
+	for i := range chances {
+		chances[i] /= total
+	}
+	return chances
+}
+
+// Average rating of the team's members, the default rating for an empty team
+func (t Team) AverageRating() int {
+	if len(t.Members) == 0 {
+		return DEFAULT_RATING
+	}
+	total := 0
+	for _, member := range t.Members {
+		total += member.Rating.Value
+	}
+	return total / len(t.Members)
+}
+
+func setRosterRating(name string, rating Rating) {
+	for i := range fighterList {
+		if fighterList[i].Name == name {
+			fighterList[i].Rating = rating
+		}
+	}
+}
+
+// Ratings of every fighter in the roster keyed by name
+func RosterRatings() map[string]Rating {
+	ratings := make(map[string]Rating, len(fighterList))
+	for _, fighter := range fighterList {
+		ratings[fighter.Name] = fighter.Rating
+	}
+	return ratings
+}
+
+// Restore previously persisted ratings, fighters missing from the map keep their current rating
+func LoadRatings(ratings map[string]Rating) {
+	for name, rating := range ratings {
+		setRosterRating(name, rating)
+	}
+}
+
+// Policy used to pick the next challenger for the fighter that won the last round
+type Matchmaker interface {
+	NextChallenger(champion Fighter, challengers []Fighter) Fighter
+}
+
+// Picks any challenger with equal probability
+type UniformRandom struct{}
+
+func (UniformRandom) NextChallenger(champion Fighter, challengers []Fighter) Fighter {
+	return challengers[rand.IntN(len(challengers))]
+}
+
+// Picks the challenger rated closest to the champion for the most even fight
+type ClosestRating struct{}
+
+func (ClosestRating) NextChallenger(champion Fighter, challengers []Fighter) Fighter {
+	closest := challengers[0]
+	for _, challenger := range challengers[1:] {
+		if ratingDistance(champion, challenger) < ratingDistance(champion, closest) {
+			closest = challenger
+		}
+	}
+	return closest
+}
+
+func ratingDistance(a Fighter, b Fighter) int {
+	distance := a.Rating.Value - b.Rating.Value
+	if distance < 0 {
+		return -distance
+	}
+	return distance
+}
+
+/*
+Treats the champion as a gatekeeper that rising challengers have to get past
+
+Challengers that gained rating in their last match are preferred, the highest rated of them is picked.
+When nobody is on the rise the closest rated challenger is picked instead
+*/
+type Gatekeeper struct{}
+
+func (Gatekeeper) NextChallenger(champion Fighter, challengers []Fighter) Fighter {
+	var rising *Fighter
+	for i, challenger := range challengers {
+		if challenger.Rating.Change <= 0 {
+			continue
+		}
+		if rising == nil || challenger.Rating.Value > rising.Rating.Value {
+			rising = &challengers[i]
+		}
+	}
+	if rising == nil {
+		return ClosestRating{}.NextChallenger(champion, challengers)
+	}
+	return *rising
+}
@@ -0,0 +1,70 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUpdateRatingsConservesPoints(t *testing.T) {
+	winner := Fighter{Name: "Winner", Rating: Rating{Value: 1400}}
+	loser := Fighter{Name: "Loser", Rating: Rating{Value: 1600}}
+	UpdateRatings(&winner, &loser)
+	if winner.Rating.Value+loser.Rating.Value != 3000 {
+		t.Errorf("rating points were not conserved: %d + %d", winner.Rating.Value, loser.Rating.Value)
+	}
+	// An upset should be worth more than half of the K factor
+	if winner.Rating.Change <= ELO_K_FACTOR/2 || loser.Rating.Change != -winner.Rating.Change {
+		t.Errorf("unexpected rating changes %d and %d", winner.Rating.Change, loser.Rating.Change)
+	}
+}
+
+func TestMatchmakers(t *testing.T) {
+	champion := Fighter{Name: "Champion", Rating: Rating{Value: 1500}}
+	challengers := []Fighter{
+		{Name: "Far", Rating: Rating{Value: 1200, Change: 10}},
+		{Name: "Close", Rating: Rating{Value: 1490, Change: -10}},
+		{Name: "Rising", Rating: Rating{Value: 1350, Change: 20}},
+	}
+	if got := (ClosestRating{}).NextChallenger(champion, challengers); got.Name != "Close" {
+		t.Errorf("closest rating picked %s", got.Name)
+	}
+	if got := (Gatekeeper{}).NextChallenger(champion, challengers); got.Name != "Rising" {
+		t.Errorf("gatekeeper picked %s", got.Name)
+	}
+}
+
+func TestChooseChallengerExcludesChampion(t *testing.T) {
+	before := fighterList
+	champion := fighterList[2]
+	for range 50 {
+		challenger, err := chooseChallenger(UniformRandom{}, champion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if challenger.Name == champion.Name {
+			t.Fatalf("champion %s was picked as its own challenger", champion.Name)
+		}
+	}
+	for i := range fighterList {
+		if fighterList[i].Name != before[i].Name {
+			t.Fatalf("roster order changed while choosing challengers")
+		}
+	}
+}
+
+func TestWinChances(t *testing.T) {
+	g := GameState{Teams: []Team{
+		NewTeam(Fighter{Rating: Rating{Value: 1600}}),
+		NewTeam(Fighter{Rating: Rating{Value: 1400}}),
+	}}
+	chances := g.WinChances()
+	if math.Abs(chances[0]-expectedScore(1600, 1400)) > 1e-9 || math.Abs(chances[0]+chances[1]-1) > 1e-9 {
+		t.Errorf("duel chances %v should match the expected score", chances)
+	}
+
+	g.Teams = append(g.Teams, NewTeam(Fighter{Rating: Rating{Value: 1400}}))
+	chances = g.WinChances()
+	if chances[1] != chances[2] || chances[0] <= chances[1] {
+		t.Errorf("royale chances %v should favour the higher rating", chances)
+	}
+}
@@ -0,0 +1,163 @@
+package game
+
+import (
+	"math/rand/v2"
+	"slices"
+)
+
+// One side of a battle, a team of a single fighter for regular one-vs-one fights
+type Team struct {
+	Members []Fighter
+}
+
+func NewTeam(members ...Fighter) Team {
+	return Team{Members: members}
+}
+
+func (f Fighter) Alive() bool {
+	return f.Health.Value > 0
+}
+
+// A side loses when every member is down
+func (t Team) Defeated() bool {
+	for _, member := range t.Members {
+		if member.Alive() {
+			return false
+		}
+	}
+	return true
+}
+
+// Sum of the health of every member, may be negative when members have been overkilled
+func (t Team) TotalHealth() int {
+	total := 0
+	for _, member := range t.Members {
+		total += member.Health.Value
+	}
+	return total
+}
+
+func (t Team) Names() []string {
+	names := make([]string, 0, len(t.Members))
+	for _, member := range t.Members {
+		names = append(names, member.Name)
+	}
+	return names
+}
+
+// Index of the living member whose attack comes up next, lowest timer first and higher speed on ties
+func (t Team) nextActor() int {
+	actor := -1
+	for i, member := range t.Members {
+		if !member.Alive() {
+			continue
+		}
+		if actor == -1 {
+			actor = i
+			continue
+		}
+		lead := t.Members[actor]
+		if member.AttackTimer.Value < lead.AttackTimer.Value ||
+			(member.AttackTimer.Value == lead.AttackTimer.Value && member.Speed.Value > lead.Speed.Value) {
+			actor = i
+		}
+	}
+	return actor
+}
+
+func (t *Team) Reset() {
+	for i := range t.Members {
+		t.Members[i].Reset()
+	}
+}
+
+type TargetingRule uint
+
+const (
+	_                    = iota
+	TARGET_LOWEST_HEALTH // 1
+	TARGET_RANDOM        // 2
+)
+
+func (t *Team) living() []*Fighter {
+	living := []*Fighter{}
+	for i := range t.Members {
+		if t.Members[i].Alive() {
+			living = append(living, &t.Members[i])
+		}
+	}
+	return living
+}
+
+// Fighters that have taunted their opponents into attacking them
+func taunting(fighters []*Fighter) []*Fighter {
+	taunting := []*Fighter{}
+	for _, fighter := range fighters {
+		if slices.ContainsFunc(fighter.Effects, func(e Effect) bool {
+			_, ok := e.(*Taunt)
+			return ok
+		}) {
+			taunting = append(taunting, fighter)
+		}
+	}
+	return taunting
+}
+
+// Choose which living enemy the attacker goes after, taunting enemies are always targeted first
+func (attacker Fighter) chooseTarget(enemies []*Fighter) *Fighter {
+	candidates := taunting(enemies)
+	if len(candidates) == 0 {
+		candidates = enemies
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch attacker.Targeting {
+	case TARGET_RANDOM:
+		return candidates[rand.IntN(len(candidates))]
+	default: // TARGET_LOWEST_HEALTH
+		return lowestHealth(candidates)
+	}
+}
+
+func lowestHealth(fighters []*Fighter) *Fighter {
+	lowest := fighters[0]
+	for _, fighter := range fighters[1:] {
+		if fighter.Health.Value < lowest.Health.Value {
+			lowest = fighter
+		}
+	}
+	return lowest
+}
+
+type AbilityTarget uint
+
+const (
+	_                  = iota
+	TARGET_ENEMY       // 1
+	TARGET_ALL_ENEMIES // 2
+	TARGET_SELF        // 3
+	TARGET_ALLY        // 4 Most wounded living ally, including the user
+	TARGET_ALL_ALLIES  // 5
+)
+
+// Fighters an ability is invoked on, based on the ability's target
+func abilityTargets(ability Ability, self *Fighter, allies *Team, enemies []*Fighter) []*Fighter {
+	switch ability.Target {
+	case TARGET_ALL_ENEMIES:
+		return enemies
+	case TARGET_SELF:
+		return []*Fighter{self}
+	case TARGET_ALLY:
+		return []*Fighter{lowestHealth(allies.living())}
+	case TARGET_ALL_ALLIES:
+		return allies.living()
+	default: // TARGET_ENEMY
+		target := self.chooseTarget(enemies)
+		if target == nil {
+			return []*Fighter{}
+		}
+		return []*Fighter{target}
+	}
+}
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+// Step a game until a winner is declared
+func playRound(t *testing.T, g *GameState) {
+	output := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(output)
+	for i := 0; g.Phase != POSTROUND; i++ {
+		if i > 10000 {
+			t.Fatalf("round did not finish after %d steps", i)
+		}
+		g.StepGame()
+	}
+}
+
+func TestTeamBattle(t *testing.T) {
+	for teamSize := 1; teamSize <= 3; teamSize++ {
+		g := NewTeamBattle(teamSize)
+		names := map[string]bool{}
+		for _, fighter := range g.AllFighters() {
+			if names[fighter.Name] {
+				t.Fatalf("%s was drawn twice for a %dv%d battle", fighter.Name, teamSize, teamSize)
+			}
+			names[fighter.Name] = true
+		}
+
+		playRound(t, &g)
+		var losers Team
+		switch g.Winner {
+		case LEFT:
+			losers = g.Teams[1]
+		case RIGHT:
+			losers = g.Teams[0]
+		default:
+			t.Fatalf("round ended without a winner")
+		}
+		if !losers.Defeated() && g.RoundTicks < ROUND_TICK_LIMIT {
+			t.Errorf("losing side of a %dv%d battle still has fighters standing", teamSize, teamSize)
+		}
+	}
+}
+
+func TestTauntDrawsAttacks(t *testing.T) {
+	attacker := Fighter{Name: "Attacker", Targeting: TARGET_LOWEST_HEALTH}
+	team := NewTeam(
+		Fighter{Name: "Wounded", Health: IntStat{Value: 1, MaxValue: 10}},
+		Fighter{Name: "Taunting", Health: IntStat{Value: 10, MaxValue: 10}, Effects: []Effect{&Taunt{NewIntStat(2)}}},
+	)
+	if target := attacker.chooseTarget(team.living()); target.Name != "Taunting" {
+		t.Errorf("expected the taunting fighter to be targeted, got %s", target.Name)
+	}
+	team.Members[1].Effects = nil
+	if target := attacker.chooseTarget(team.living()); target.Name != "Wounded" {
+		t.Errorf("expected the lowest health fighter to be targeted, got %s", target.Name)
+	}
+}
+
+func TestBattleRoyale(t *testing.T) {
+	for size := 4; size <= len(fighterList); size++ {
+		g := NewBattleRoyale(size)
+		if !g.FreeForAll() {
+			t.Fatalf("battle royale of %d fighters is not a free-for-all", size)
+		}
+		playRound(t, &g)
+		if len(g.Placements) != size {
+			t.Fatalf("expected %d placements, got %v", size, g.Placements)
+		}
+		winnerIdx := g.WinningTeam()
+		if g.Placement(winnerIdx) != 1 {
+			t.Errorf("winner was placed %d", g.Placement(winnerIdx))
+		}
+		timeUp := g.RoundTicks >= ROUND_TICK_LIMIT // Healers left alone are decided on health instead
+		for i, team := range g.Teams {
+			if i != winnerIdx && !team.Defeated() && !timeUp {
+				t.Errorf("%s is still standing but placed %d", team.Members[0].Name, g.Placement(i))
+			}
+		}
+
+		winnerName := g.Teams[winnerIdx].Members[0].Name
+		g.PhaseTimer = 0
+		g.StepGame()
+		if len(g.Teams) != size || g.Teams[0].Members[0].Name != winnerName {
+			t.Errorf("next battle royale should keep the winner and field %d fighters", size)
+		}
+	}
+}
+
+func TestRoundTimeLimit(t *testing.T) {
+	g := NewBattleRoyale(4)
+	for g.Phase != ROUND {
+		g.StepGame()
+	}
+	g.DrainEvents()
+	for i := range g.Teams {
+		g.Teams[i].Members[0].Health.Value = 10 + i
+	}
+	g.Teams[0].Members[0].Health.Value = 0
+	g.RoundTicks = ROUND_TICK_LIMIT - 1
+	g.StepGame()
+	if g.Phase != POSTROUND || len(g.Placements) != 4 {
+		t.Fatalf("expected the round to be decided once the time is up, got phase %v and placements %v", g.Phase, g.Placements)
+	}
+	if g.WinningTeam() != 3 || g.Placement(0) != 4 {
+		t.Errorf("expected the healthiest fighter to win and the fallen one to be last, got placements %v", g.Placements)
+	}
+}
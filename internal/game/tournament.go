@@ -0,0 +1,193 @@
+package game
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+type TournamentFormat uint
+
+const (
+	_                  = iota
+	SINGLE_ELIMINATION // 1
+	DOUBLE_ELIMINATION // 2
+)
+
+type BracketKind uint
+
+const (
+	_           = iota
+	WINNERS     // 1
+	LOSERS      // 2
+	GRAND_FINAL // 3
+)
+
+type BracketMatch struct {
+	Bracket BracketKind
+	Round   int
+	Left    string
+	Right   string
+	Winner  string // Empty until the match has been fought
+}
+
+/*
+Bracket state of a tournament between every fighter in the roster
+
+Matches are generated one round at a time from the fighters that are still alive, so the same
+rules produce both a single-elimination bracket (out after one loss) and a double-elimination
+bracket (out after two losses, with a winners side, a losers side and a grand final)
+*/
+type Tournament struct {
+	ID       int64
+	Format   TournamentFormat
+	Entrants []string       // Fighter names in seed order
+	Losses   map[string]int // Number of matches lost by each entrant
+	Matches  []BracketMatch
+	Current  int // Index into Matches of the match being fought
+	Round    int
+	Champion string
+}
+
+func NewTournament(format TournamentFormat) *Tournament {
+	entrants := make([]string, 0, len(fighterList))
+	for _, fighter := range fighterList {
+		entrants = append(entrants, fighter.Name)
+	}
+	rand.Shuffle(len(entrants), func(i, j int) {
+		entrants[i], entrants[j] = entrants[j], entrants[i]
+	})
+
+	t := &Tournament{
+		Format:   format,
+		Entrants: entrants,
+		Losses:   make(map[string]int, len(entrants)),
+	}
+	for _, name := range entrants {
+		t.Losses[name] = 0
+	}
+	t.scheduleRound()
+	return t
+}
+
+// Number of losses after which a fighter is knocked out of the tournament
+func (t *Tournament) maxLosses() int {
+	if t.Format == DOUBLE_ELIMINATION {
+		return 2
+	}
+	return 1
+}
+
+// Entrants with the given amount of losses, in seed order
+func (t *Tournament) pool(losses int) []string {
+	pool := []string{}
+	for _, name := range t.Entrants {
+		if t.Losses[name] == losses {
+			pool = append(pool, name)
+		}
+	}
+	return pool
+}
+
+func (t *Tournament) alive() []string {
+	alive := []string{}
+	for _, name := range t.Entrants {
+		if t.Losses[name] < t.maxLosses() {
+			alive = append(alive, name)
+		}
+	}
+	return alive
+}
+
+// Pair the top seed against the bottom seed and so on, the top seed gets a bye on odd sized pools
+func (t *Tournament) pairPool(pool []string, kind BracketKind) {
+	if len(pool)%2 == 1 {
+		pool = pool[1:]
+	}
+	for i := 0; i < len(pool)/2; i++ {
+		t.Matches = append(t.Matches, BracketMatch{
+			Bracket: kind,
+			Round:   t.Round,
+			Left:    pool[i],
+			Right:   pool[len(pool)-1-i],
+		})
+	}
+}
+
+// Generate the next round of matches, or crown a champion when a single fighter remains
+func (t *Tournament) scheduleRound() {
+	alive := t.alive()
+	if len(alive) == 1 {
+		t.Champion = alive[0]
+		return
+	}
+	t.Round += 1
+	if len(alive) == 2 && t.Format == DOUBLE_ELIMINATION {
+		t.pairPool(alive, GRAND_FINAL)
+		return
+	}
+	t.pairPool(t.pool(0), WINNERS)
+	if t.Format == DOUBLE_ELIMINATION {
+		t.pairPool(t.pool(1), LOSERS)
+	}
+}
+
+func (t *Tournament) Finished() bool {
+	return t.Champion != ""
+}
+
+// Returns true while no match of the tournament has been decided
+func (t *Tournament) NotStarted() bool {
+	return t.Current == 0 && t.Matches[0].Winner == ""
+}
+
+func (t *Tournament) CurrentMatch() *BracketMatch {
+	if t.Finished() || t.Current >= len(t.Matches) {
+		return nil
+	}
+	return &t.Matches[t.Current]
+}
+
+// Record the winner of the current match and move on to the next one, scheduling a new round when needed
+func (t *Tournament) RecordResult(winnerName string) error {
+	match := t.CurrentMatch()
+	if match == nil {
+		return fmt.Errorf("error: tournament has no match in progress")
+	}
+	var loserName string
+	switch winnerName {
+	case match.Left:
+		loserName = match.Right
+	case match.Right:
+		loserName = match.Left
+	default:
+		return fmt.Errorf("error: fighter %s is not part of the current match", winnerName)
+	}
+	match.Winner = winnerName
+	t.Losses[loserName] += 1
+	t.Current += 1
+	if t.Current >= len(t.Matches) {
+		t.scheduleRound()
+	}
+	return nil
+}
+
+// Group matches by round for display
+func (t *Tournament) Rounds() [][]BracketMatch {
+	rounds := make([][]BracketMatch, t.Round)
+	for _, match := range t.Matches {
+		rounds[match.Round-1] = append(rounds[match.Round-1], match)
+	}
+	return rounds
+}
+
+func (k BracketKind) String() string {
+	switch k {
+	case WINNERS:
+		return "Winners"
+	case LOSERS:
+		return "Losers"
+	case GRAND_FINAL:
+		return "Grand Final"
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package game
+
+import (
+	"testing"
+)
+
+// Play a tournament to completion, the left fighter winning every match
+func playTournament(t *testing.T, format TournamentFormat) *Tournament {
+	tournament := NewTournament(format)
+	for i := 0; !tournament.Finished(); i++ {
+		if i > 100 {
+			t.Fatalf("tournament did not finish after %d matches", i)
+		}
+		match := tournament.CurrentMatch()
+		if match == nil {
+			t.Fatalf("unfinished tournament has no current match")
+		}
+		if err := tournament.RecordResult(match.Left); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tournament
+}
+
+func TestSingleElimination(t *testing.T) {
+	tournament := playTournament(t, SINGLE_ELIMINATION)
+	if len(tournament.Matches) != len(fighterList)-1 {
+		t.Errorf("expected %d matches, got %d", len(fighterList)-1, len(tournament.Matches))
+	}
+	for name, losses := range tournament.Losses {
+		if name == tournament.Champion && losses != 0 {
+			t.Errorf("champion %s has %d losses", name, losses)
+		}
+	}
+}
+
+func TestDoubleElimination(t *testing.T) {
+	tournament := playTournament(t, DOUBLE_ELIMINATION)
+	for name, losses := range tournament.Losses {
+		if name != tournament.Champion && losses != 2 {
+			t.Errorf("%s was knocked out with %d losses", name, losses)
+		}
+	}
+	final := tournament.Matches[len(tournament.Matches)-1]
+	if final.Bracket != GRAND_FINAL {
+		t.Errorf("expected last match to be the grand final, got %s", final.Bracket)
+	}
+}
+
+func TestRecordResultRejectsOutsider(t *testing.T) {
+	tournament := NewTournament(SINGLE_ELIMINATION)
+	if err := tournament.RecordResult("Not a fighter"); err == nil {
+		t.Error("expected an error recording a winner outside of the current match")
+	}
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"js-bet/internal/game"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// How long the game loop may go without ticking before the server stops reporting itself ready
+const READY_TICK_AGE = 5 * time.Second
+
+// How long the readiness probe waits on the database
+const READY_DB_TIMEOUT = time.Second
+
+// Set at build time with -ldflags "-X js-bet/internal.Version=...", read from the build info otherwise
+var Version string
+
+var startedAt = time.Now()
+
+// Last tick of the game loop, stored by runGame for the probes
+type arenaTick struct {
+	At    time.Time
+	Round int
+	Phase string
+}
+
+var lastTick atomic.Pointer[arenaTick]
+
+func recordTick(gs game.GameState) {
+	lastTick.Store(&arenaTick{time.Now(), gs.Round, gs.Phase.String()})
+}
+
+func version() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return info.Main.Version
+}
+
+// Answers as long as the process can serve requests at all
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintln(w, "ok")
+}
+
+// Reasons the server cannot take traffic yet, empty when it is ready. The response is public so errors are only logged
+func readinessProblems(r *http.Request) []string {
+	problems := []string{}
+	ctx, cancel := context.WithTimeout(r.Context(), READY_DB_TIMEOUT)
+	defer cancel()
+	if err := db.Ping(ctx); err != nil {
+		requestLogger(r).Error("Database unreachable", "err", err)
+		problems = append(problems, "database unreachable")
+	}
+	if len(siteAssets().IconsSvgs) == 0 {
+		problems = append(problems, "icons not loaded")
+	}
+	if tick := lastTick.Load(); tick == nil {
+		problems = append(problems, "game loop has not ticked yet")
+	} else if age := time.Since(tick.At); age > READY_TICK_AGE {
+		problems = append(problems, fmt.Sprintf("game loop last ticked %s ago", age.Round(time.Second)))
+	}
+	return problems
+}
+
+// Answers with a 503 listing what is wrong while the server should not be sent traffic
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	problems := readinessProblems(r)
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, problem := range problems {
+			fmt.Fprintln(w, problem)
+		}
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+type ServerStatus struct {
+	Version       string    `json:"version"`
+	StartedAt     time.Time `json:"startedAt"`
+	UptimeSeconds int64     `json:"uptimeSeconds"`
+	Round         int       `json:"round"` // Rounds started since the server started
+	Phase         string    `json:"phase"`
+	LastTick      time.Time `json:"lastTick,omitzero"`
+	Clients       int       `json:"clients"` // Clients connected to the game and API streams
+}
+
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := ServerStatus{
+		Version:       version(),
+		StartedAt:     startedAt,
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		Clients:       int(streamClients.Value("game") + streamClients.Value("api")),
+	}
+	if tick := lastTick.Load(); tick != nil {
+		status.Round, status.Phase, status.LastTick = tick.Round, tick.Phase, tick.At
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"encoding/json"
+	"js-bet/internal/assets"
+	"js-bet/internal/game"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	useTestDB(t)
+	previousSite, previousTick := site.Load(), lastTick.Load()
+	t.Cleanup(func() {
+		site.Store(previousSite)
+		lastTick.Store(previousTick)
+	})
+	loaded := &staticSite{Assets: assets.New()}
+	site.Store(loaded)
+	lastTick.Store(nil)
+
+	probe := func() (int, string) {
+		w := httptest.NewRecorder()
+		handleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code, w.Body.String()
+	}
+	if code, body := probe(); code != http.StatusServiceUnavailable || !strings.Contains(body, "icons") || !strings.Contains(body, "ticked") {
+		t.Errorf("expected missing icons and ticks to be reported, got %d %q", code, body)
+	}
+
+	loaded.Assets.IconsSvgs["React"] = "<svg></svg>"
+	gs := game.New()
+	gs.Round = 3
+	recordTick(gs)
+	if code, body := probe(); code != http.StatusOK {
+		t.Errorf("expected the server to be ready, got %d %q", code, body)
+	}
+
+	lastTick.Store(&arenaTick{At: time.Now().Add(-2 * READY_TICK_AGE)})
+	if code, body := probe(); code != http.StatusServiceUnavailable || !strings.Contains(body, "last ticked") {
+		t.Errorf("expected a stalled game loop to be reported, got %d %q", code, body)
+	}
+
+	db.conn.Close()
+	if code, body := probe(); code != http.StatusServiceUnavailable || !strings.Contains(body, "database unreachable") {
+		t.Errorf("expected a closed database to be reported, got %d %q", code, body)
+	} else if strings.Contains(body, "sql") {
+		t.Errorf("database errors should only be logged, got %q", body)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	previousTick := lastTick.Load()
+	t.Cleanup(func() { lastTick.Store(previousTick) })
+	gs := game.New()
+	gs.Round = 7
+	recordTick(gs)
+
+	w := httptest.NewRecorder()
+	handleStatus(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var status ServerStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Round != 7 || status.Phase != gs.Phase.String() || status.Version == "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
@@ -1,18 +1,23 @@
 package internal
 
+import "sync/atomic"
+
 var userClientMap map[string]chan []byte = make(map[string]chan []byte, 10)
 
 type Hub struct {
-	broadcast  chan []byte // Messages of HTML that are sent out to any user showing the global state
+	name       string      // Stream the hub serves, used to label its metrics
+	broadcast  chan []byte // Framed server-sent events that are sent out to any user showing the global state
 	register   chan Client
 	unregister chan Client
-	clients    map[Client]struct{}
+	clients    map[Client]struct{} // Only touched by Run
+	listening  atomic.Int64        // Number of clients, kept by Run for other goroutines to read
 }
 
 type Client chan []byte
 
-func NewHub() *Hub {
+func NewHub(name string) *Hub {
 	return &Hub{
+		name:       name,
 		broadcast:  make(chan []byte),
 		register:   make(chan Client),
 		unregister: make(chan Client),
@@ -25,16 +30,26 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.clients[client] = struct{}{}
+			h.listening.Store(int64(len(h.clients)))
+			streamClients.Set(float64(len(h.clients)), h.name)
 		case client := <-h.unregister:
 			delete(h.clients, client)
 			close(client)
+			h.listening.Store(int64(len(h.clients)))
+			streamClients.Set(float64(len(h.clients)), h.name)
 		case html := <-h.broadcast:
 			for client := range h.clients {
 				select {
 				case client <- html:
 				default:
+					droppedMessages.Inc(h.name)
 				}
 			}
 		}
 	}
 }
+
+// Whether any client is connected, safe to call from any goroutine
+func (h *Hub) HasClients() bool {
+	return h.listening.Load() > 0
+}
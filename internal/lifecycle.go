@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"js-bet/internal/game"
+	"strings"
+)
+
+// Number of ticks a lifecycle banner stays on screen
+const BANNER_TICKS = 3
+
+// Banners waiting behind the one on screen, older ones are dropped so the banner never lags far behind the arena
+const BANNER_QUEUE_LIMIT = 2
+
+// Announcement shown above the arena after a lifecycle event
+type Banner struct {
+	Text   string
+	Ticks  int
+	queued []string // Announcements waiting for the one on screen to finish
+}
+
+var banner Banner
+
+// Announce an event, waiting for the banner on screen to finish so each one is seen
+func (b *Banner) Show(text string) {
+	if b.Text == "" {
+		b.Text = text
+		b.Ticks = BANNER_TICKS
+		return
+	}
+	b.queued = append(b.queued, text)
+	if len(b.queued) > BANNER_QUEUE_LIMIT {
+		b.queued = b.queued[len(b.queued)-BANNER_QUEUE_LIMIT:]
+	}
+}
+
+// Count down the banner, moving on to the next one once it has been shown for BANNER_TICKS
+func (b *Banner) Step() {
+	if b.Ticks > 0 {
+		b.Ticks -= 1
+	}
+	if b.Ticks > 0 {
+		return
+	}
+	if len(b.queued) > 0 {
+		b.Text, b.queued = b.queued[0], b.queued[1:]
+		b.Ticks = BANNER_TICKS
+	} else {
+		b.Text = ""
+	}
+}
+
+// React to the lifecycle events emitted by the last step of the game
+func dispatchLifecycleEvents(gs *game.GameState) {
+	banner.Step()
+	events := gs.DrainEvents()
+	// Events of the same tick share one banner
+	announced := make([]string, len(events))
+	for i, event := range events {
+		announced[i] = event.String()
+	}
+	if len(announced) > 0 {
+		banner.Show(strings.Join(announced, " · "))
+	}
+	for _, event := range events {
+		arenaLogger.Debug("Lifecycle event", "round", gs.Round, "event", event.String())
+		switch event.Kind {
+		case game.BETTING_OPENED:
+			OpenBetting(*gs)
+			if gs.Tournament != nil && gs.Tournament.ID == 0 {
+				saveTournament(gs.Tournament)
+			}
+		case game.BETTING_CLOSED:
+			CloseBetting()
+		case game.ROUND_STARTED:
+			arenaLogger.Info("Round started", "round", gs.Round, "teams", gs.TeamNames())
+			go emitWebhook(WEBHOOK_ROUND_STARTED, newRoundStarted(*gs))
+		case game.ROUND_ABORTED:
+			arenaLogger.Info("Round called off", "round", gs.Round, "reason", gs.Status)
+			VoidRoundBets()
+		case game.WINNER_DECLARED:
+			arenaLogger.Info("Round won", "round", gs.Round, "winners", event.Names)
+			go emitWebhook(WEBHOOK_ROUND_ENDED, newRoundEnded(*gs))
+			SettleBets(*gs)
+			if err := db.SaveFighterRatings(gs.AllFighters()...); err != nil {
+				arenaLogger.Error("Unable to save fighter ratings", "round", gs.Round, "err", err)
+			}
+			if gs.Tournament != nil {
+				saveTournament(gs.Tournament)
+			}
+		}
+	}
+}
+
+func saveTournament(t *game.Tournament) {
+	if err := db.SaveTournament(t); err != nil {
+		arenaLogger.Error("Unable to save tournament", "tournament_id", t.ID, "err", err)
+	}
+}
@@ -0,0 +1,33 @@
+package internal
+
+import "testing"
+
+func TestBannerQueue(t *testing.T) {
+	var b Banner
+	b.Show("Betting closed")
+	b.Show("Round started")
+	for range BANNER_TICKS - 1 {
+		b.Step()
+		if b.Text != "Betting closed" {
+			t.Fatalf("expected the first banner to stay up, got %q", b.Text)
+		}
+	}
+	b.Step()
+	if b.Text != "Round started" || b.Ticks != BANNER_TICKS {
+		t.Fatalf("expected the queued banner to be shown in full next, got %q for %d ticks", b.Text, b.Ticks)
+	}
+	for range BANNER_TICKS {
+		b.Step()
+	}
+	if b.Text != "" {
+		t.Errorf("expected the banner to clear once the queue is empty, got %q", b.Text)
+	}
+
+	b.Show("KO 1")
+	for _, text := range []string{"KO 2", "KO 3", "KO 4"} {
+		b.Show(text)
+	}
+	if len(b.queued) != BANNER_QUEUE_LIMIT || b.queued[0] != "KO 3" {
+		t.Errorf("expected only the latest %d banners to wait, got %v", BANNER_QUEUE_LIMIT, b.queued)
+	}
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestLogging(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	if err := ConfigureLogging(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+	if err := ConfigureLogging(&bytes.Buffer{}, "json", "loud"); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+
+	var out bytes.Buffer
+	if err := ConfigureLogging(&out, "json", "info"); err != nil {
+		t.Fatal(err)
+	}
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestLogger(r).Debug("Hidden below the level")
+		requestLogger(r).Info("Handled")
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/state", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single json entry, got %q", out.String())
+	}
+	if entry["msg"] != "Handled" || entry["path"] != "/state" || entry["request_id"] != w.Header().Get("X-Request-ID") {
+		t.Errorf("expected the entry to carry the request, got %v", entry)
+	}
+}
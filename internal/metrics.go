@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"io"
+	"js-bet/internal/metrics"
+	"time"
+)
+
+// Metrics served on /metrics
+var (
+	streamClients   = metrics.NewGauge("jsbet_stream_clients", "Clients connected to a server-sent event stream", "stream")
+	droppedMessages = metrics.NewCounter("jsbet_stream_dropped_messages_total", "Messages skipped for clients too slow to take them", "stream")
+	bytesSent       = metrics.NewCounter("jsbet_game_stream_bytes_total", "Bytes written to clients of the game stream after compression", "encoding")
+	renderSeconds   = metrics.NewHistogram("jsbet_render_seconds", "Time taken to render a component of the game stream", metrics.DurationBuckets, "component")
+	tickSeconds     = metrics.NewHistogram("jsbet_tick_seconds", "Time taken to step, publish and render a tick of the game", metrics.DurationBuckets)
+	betsPlaced      = metrics.NewCounter("jsbet_bets_placed_total", "Bets placed or replaced", "market")
+	goldWagered     = metrics.NewCounter("jsbet_gold_wagered_total", "Gold put on bets as they are placed", "market")
+	roundBets       = metrics.NewHistogram("jsbet_round_bets", "Bets settled at the end of each round", []float64{0, 1, 2, 5, 10, 25, 50, 100}, "market")
+	roundGold       = metrics.NewHistogram("jsbet_round_gold_wagered", "Gold wagered on each round as it is settled", []float64{0, 10, 50, 100, 500, 1000, 5000}, "market")
+	logins          = metrics.NewCounter("jsbet_logins_total", "Login attempts by result", "result")
+	refreshes       = metrics.NewCounter("jsbet_session_refreshes_total", "Refresh tokens traded for new tokens by result", "result")
+	rateLimited     = metrics.NewCounter("jsbet_rate_limited_total", "Requests refused for coming too often", "path")
+	querySeconds    = metrics.NewHistogram("jsbet_db_query_seconds", "Time taken by database queries", metrics.DurationBuckets, "query")
+)
+
+// Record the bets of a market settled at the end of a round
+func observeRoundBets(market string, bets int, gold int) {
+	roundBets.Observe(float64(bets), market)
+	roundGold.Observe(float64(gold), market)
+}
+
+// Meant to be deferred at the start of a query with the time it started
+func observeQuery(query string, start time.Time) {
+	querySeconds.Observe(time.Since(start).Seconds(), query)
+}
+
+// Writer counting the bytes passed through it into bytesSent
+type countingWriter struct {
+	w        io.Writer
+	encoding string
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	bytesSent.Add(float64(n), c.encoding)
+	return n, err
+}
@@ -0,0 +1,263 @@
+/*
+Counters, gauges and histograms exposed in the Prometheus text format
+
+Metrics register themselves when created and are written out by Handler, so scrapers can read them from
+the server without any other service running alongside it
+*/
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var registryMu sync.Mutex
+var registry []metric
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// Values of a metric kept apart by the values of its labels
+type series struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64 // Keyed by the label values joined with a zero byte
+}
+
+func newSeries(name string, help string, kind string, labels []string) *series {
+	return &series{name: name, help: help, kind: kind, labels: labels, values: map[string]float64{}}
+}
+
+func (s *series) key(labelValues []string) string {
+	if len(labelValues) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", s.name, len(s.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\x00")
+}
+
+func (s *series) add(value float64, labelValues []string) {
+	key := s.key(labelValues)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] += value
+}
+
+func (s *series) set(value float64, labelValues []string) {
+	key := s.key(labelValues)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+func (s *series) get(labelValues []string) float64 {
+	key := s.key(labelValues)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+func writeHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Label pairs of a sample in the form {a="x",b="y"}, extra is appended as is for histogram buckets
+func formatLabels(names []string, key string, extra string) string {
+	pairs := []string{}
+	if len(names) > 0 {
+		for i, value := range strings.Split(key, "\x00") {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, names[i], labelEscaper.Replace(value)))
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (s *series) write(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeHeader(w, s.name, s.help, s.kind)
+	if len(s.labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", s.name, formatValue(s.values[""]))
+		return
+	}
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", s.name, formatLabels(s.labels, key, ""), formatValue(s.values[key]))
+	}
+}
+
+// Value that only goes up, such as the number of requests served
+type Counter struct {
+	*series
+}
+
+func NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{newSeries(name, help, "counter", labels)}
+	register(c)
+	return c
+}
+
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.name))
+	}
+	c.add(value, labelValues)
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.get(labelValues)
+}
+
+// Value that goes up and down, such as the number of connected clients
+type Gauge struct {
+	*series
+}
+
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	g := &Gauge{newSeries(name, help, "gauge", labels)}
+	register(g)
+	return g
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.set(value, labelValues)
+}
+
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.add(value, labelValues)
+}
+
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.get(labelValues)
+}
+
+// Buckets for durations in seconds, from a tenth of a millisecond to a second
+var DurationBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+type histogramValues struct {
+	counts []uint64 // Observations in each bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Distribution of observed values counted into buckets by their upper bounds
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValues
+}
+
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, labels: labels, buckets: slices.Sorted(slices.Values(buckets)), values: map[string]*histogramValues{}}
+	register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.name, len(h.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\x00")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	values, ok := h.values[key]
+	if !ok {
+		values = &histogramValues{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = values
+	}
+	if i, _ := slices.BinarySearch(h.buckets, value); i < len(h.buckets) {
+		values.counts[i] += 1
+	}
+	values.count += 1
+	values.sum += value
+}
+
+// Number of values observed so far
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if values, ok := h.values[strings.Join(labelValues, "\x00")]; ok {
+		return values.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		values := h.values[key]
+		cumulative := uint64(0)
+		for i, bound := range h.buckets {
+			cumulative += values.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, `le="`+formatValue(bound)+`"`), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, `le="+Inf"`), values.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, ""), formatValue(values.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), values.count)
+	}
+}
+
+// Write every registered metric in the text exposition format
+func WriteAll(w io.Writer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, m := range registry {
+		m.write(w)
+	}
+}
+
+// Serves every registered metric for scrapers
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteAll(w)
+	})
+}
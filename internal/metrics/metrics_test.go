@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	requests := NewCounter("test_requests_total", "Requests served", "code")
+	requests.Inc("200")
+	requests.Add(2, "500")
+	clients := NewGauge("test_clients", "Connected clients")
+	clients.Set(3)
+	latency := NewHistogram("test_latency_seconds", "Request latency", []float64{0.1, 1}, "path")
+	latency.Observe(0.05, `/a"b`)
+	latency.Observe(0.1, `/a"b`)
+	latency.Observe(5, `/a"b`)
+
+	var out strings.Builder
+	WriteAll(&out)
+	expected := []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{code="200"} 1`,
+		`test_requests_total{code="500"} 2`,
+		"# TYPE test_clients gauge",
+		"test_clients 3",
+		"# TYPE test_latency_seconds histogram",
+		`test_latency_seconds_bucket{path="/a\"b",le="0.1"} 2`,
+		`test_latency_seconds_bucket{path="/a\"b",le="1"} 2`,
+		`test_latency_seconds_bucket{path="/a\"b",le="+Inf"} 3`,
+		`test_latency_seconds_sum{path="/a\"b"} 5.15`,
+		`test_latency_seconds_count{path="/a\"b"} 3`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("expected %q in the output:\n%s", line, out.String())
+		}
+	}
+}
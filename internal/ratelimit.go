@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"errors"
+	"js-bet/internal/components"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often buckets that have filled back up are dropped, so clients seen once do not stay in memory
+const RATE_LIMIT_PRUNE_INTERVAL = 10 * time.Minute
+
+// Limits on how often browsers and bots may log in and bet, zero disables a limit
+type RateLimits struct {
+	LoginsPerMinute int           // Login attempts from one address
+	SignupsPerHour  int           // Accounts created from one address
+	BetsPerMinute   int           // Bets placed by one user, and separately from one address
+	LockoutAfter    int           // Failed logins in a row before an account is locked
+	LockoutDuration time.Duration // How long an account stays locked
+}
+
+var limits RateLimits
+var requestLimiter = newRateLimiter()
+var lockouts = newLoginLockouts()
+
+// Token bucket per key, refilled continuously and holding at most one period's worth of requests
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	pruned  time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	per    time.Duration
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take a token from the key's bucket, returns false when the key has used up its requests for now
+func (l *rateLimiter) allow(key string, perMinute int) bool {
+	return l.wait(key, perMinute, time.Minute) == 0
+}
+
+// Take a token from the key's bucket, or return how long until one is available when it is empty
+func (l *rateLimiter) wait(key string, limit int, per time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.prune(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit), last: now, per: per}
+		l.buckets[key] = b
+	}
+	refill := float64(limit) / float64(per)
+	b.tokens = min(b.tokens+float64(now.Sub(b.last))*refill, float64(limit))
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1-b.tokens)/refill) + 1
+	}
+	b.tokens -= 1
+	return 0
+}
+
+// Drop the buckets that are full again, which behave the same as a missing bucket
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < RATE_LIMIT_PRUNE_INTERVAL {
+		return
+	}
+	l.pruned = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= b.per {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Failed logins in a row per account, locking the account once there are too many
+type loginLockouts struct {
+	mu       sync.Mutex
+	accounts map[string]*lockout
+	now      func() time.Time
+}
+
+type lockout struct {
+	failures int
+	until    time.Time
+}
+
+func newLoginLockouts() *loginLockouts {
+	return &loginLockouts{
+		accounts: make(map[string]*lockout),
+		now:      time.Now,
+	}
+}
+
+// How long until the account can be logged into again, zero when it is not locked
+func (l *loginLockouts) lockedFor(name string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	account, ok := l.accounts[name]
+	if !ok {
+		return 0
+	}
+	return max(account.until.Sub(l.now()), 0)
+}
+
+// Count a failed login, locking the account for the given duration once it failed too often
+func (l *loginLockouts) fail(name string, after int, duration time.Duration) {
+	if after <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	account, ok := l.accounts[name]
+	if !ok {
+		account = &lockout{}
+		l.accounts[name] = account
+	}
+	account.failures += 1
+	if account.failures >= after {
+		account.failures = 0
+		account.until = l.now().Add(duration)
+	}
+}
+
+func (l *loginLockouts) succeed(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.accounts, name)
+}
+
+// Returned when a client has to wait before trying again, answered with a 429
+type rateLimitError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e rateLimitError) Error() string {
+	return e.Message
+}
+
+/*
+Address the request came from, used to limit clients who are not logged in
+
+Only the connection's address is used, headers such as X-Forwarded-For are set by the client and could be used
+to dodge the limits
+*/
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+/*
+Log a user in, signing them up when the name is new, unless the address or account tried too often
+
+Attempts are limited per address, accounts are locked after failing too many times in a row, and addresses can
+only create a few accounts an hour so nobody can fill the database with users
+*/
+func login(r *http.Request, name string, pass string) (int64, error) {
+	ip := clientIP(r)
+	if wait := requestLimiter.wait("login:"+ip, limits.LoginsPerMinute, time.Minute); wait > 0 {
+		return 0, rateLimitError{"Too many login attempts, try again in a minute", wait}
+	}
+	if wait := lockouts.lockedFor(name); wait > 0 {
+		return 0, rateLimitError{"Too many failed logins, this account is locked for now", wait}
+	}
+	exists, err := db.UserExists(name)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		if wait := requestLimiter.wait("signup:"+ip, limits.SignupsPerHour, time.Hour); wait > 0 {
+			return 0, rateLimitError{"Too many accounts created from this address, try again later", wait}
+		}
+	}
+	userID, err := db.CheckAddUser(name, pass)
+	if errors.Is(err, errWrongPassword) {
+		lockouts.fail(name, limits.LockoutAfter, limits.LockoutDuration)
+		return 0, err
+	} else if err != nil {
+		return 0, err
+	}
+	lockouts.succeed(name)
+	return userID, nil
+}
+
+// Throttle bets per user and per address, goes after authMiddlewareStrict
+func limitBets(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(userIDKey).(int64)
+		wait := requestLimiter.wait("bet:user:"+strconv.FormatInt(userID, 10), limits.BetsPerMinute, time.Minute)
+		if wait == 0 {
+			wait = requestLimiter.wait("bet:ip:"+clientIP(r), limits.BetsPerMinute, time.Minute)
+		}
+		if wait > 0 {
+			writeRateLimited(w, r, rateLimitError{"Too many bets, slow down", wait})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+/*
+Answer with a 429 telling the client when to try again
+
+API clients get a JSON error, htmx requests get a popup retargeted over the login popup since the bet forms
+would otherwise append the message to themselves
+*/
+func writeRateLimited(w http.ResponseWriter, r *http.Request, err rateLimitError) {
+	requestLogger(r).Info("Request rate limited", "err", err.Message, "retry_after", err.RetryAfter)
+	rateLimited.Inc(r.URL.Path)
+	w.Header().Set("Retry-After", strconv.Itoa(int((err.RetryAfter+time.Second-1)/time.Second)))
+	if strings.HasPrefix(r.URL.Path, API_PREFIX) {
+		writeAPIError(w, http.StatusTooManyRequests, err.Message)
+		return
+	}
+	if r.Header.Get("HX-Request") == "" {
+		http.Error(w, err.Message, http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("HX-Retarget", "#popup")
+	w.Header().Set("HX-Reswap", "outerMorph")
+	w.WriteHeader(http.StatusTooManyRequests)
+	components.PopupError(err.Message).Render(r.Context(), w)
+}
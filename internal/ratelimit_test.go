@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Apply the given limits with fresh buckets for the length of a test
+func useLimits(t *testing.T, configured RateLimits) {
+	previous := limits
+	limits = configured
+	requestLimiter = newRateLimiter()
+	lockouts = newLoginLockouts()
+	t.Cleanup(func() {
+		limits = previous
+		requestLimiter = newRateLimiter()
+		lockouts = newLoginLockouts()
+	})
+}
+
+func postLogin(handler http.Handler, ip string, name string, pass string) *httptest.ResponseRecorder {
+	form := url.Values{"name": {name}, "pass": {pass}}
+	r := httptest.NewRequest(http.MethodPost, "/user/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("HX-Request", "true")
+	r.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter()
+	limiter.now = func() time.Time { return now }
+	for range 2 {
+		if wait := limiter.wait("key", 2, time.Hour); wait != 0 {
+			t.Fatalf("expected requests within the limit to go through, waited %v", wait)
+		}
+	}
+	if wait := limiter.wait("key", 2, time.Hour); wait < 29*time.Minute || wait > 31*time.Minute {
+		t.Errorf("expected to wait half an hour for the next token, got %v", wait)
+	}
+	if wait := limiter.wait("key", 0, time.Hour); wait != 0 {
+		t.Error("a limit of zero should never limit")
+	}
+
+	now = now.Add(2 * time.Hour)
+	limiter.wait("other", 2, time.Minute)
+	if _, ok := limiter.buckets["key"]; ok {
+		t.Error("expected full buckets to be pruned")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	useTestDB(t)
+	useLimits(t, RateLimits{LockoutAfter: 3, LockoutDuration: time.Minute})
+	now := time.Now()
+	lockouts.now = func() time.Time { return now }
+	handler := routes(http.NotFoundHandler())
+	postLogin(handler, "10.0.0.1", "victim", "secret")
+
+	for i := range 3 {
+		if w := postLogin(handler, "10.0.0.2", "victim", "guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong password %d should be unauthorized, got %d", i+1, w.Code)
+		}
+	}
+	w := postLogin(handler, "10.0.0.1", "victim", "secret")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the account to be locked, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected to retry once the lockout ends, got %q", w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get("HX-Retarget") != "#popup" || !strings.Contains(w.Body.String(), `id="popup"`) {
+		t.Error("expected htmx requests to be answered with a popup")
+	}
+
+	now = now.Add(time.Minute)
+	if w := postLogin(handler, "10.0.0.1", "victim", "secret"); w.Code != http.StatusFound {
+		t.Errorf("expected to log in once the lockout ended, got %d", w.Code)
+	}
+}
+
+func TestLoginDatabaseError(t *testing.T) {
+	useTestDB(t)
+	useLimits(t, RateLimits{LockoutAfter: 1, LockoutDuration: time.Minute})
+	db.CheckAddUser("player", "pass")
+	r := httptest.NewRequest(http.MethodPost, "/user/login", nil)
+	if _, err := login(r, "player", "guess"); !errors.Is(err, errWrongPassword) {
+		t.Errorf("expected a wrong password, got %v", err)
+	}
+	lockouts.succeed("player")
+
+	db.conn.Close()
+	if _, err := db.CheckAddUser("player", "pass"); err == nil {
+		t.Error("expected the database failure to be returned instead of a user")
+	}
+	if userID, err := login(r, "player", "pass"); err == nil || errors.Is(err, errWrongPassword) {
+		t.Errorf("expected the database failure to be returned, got user %d and %v", userID, err)
+	}
+	if wait := lockouts.lockedFor("player"); wait > 0 {
+		t.Error("a database failure should not count towards locking the account")
+	}
+}
+
+func TestSignupCap(t *testing.T) {
+	useTestDB(t)
+	useLimits(t, RateLimits{SignupsPerHour: 2, LoginsPerMinute: 5})
+	handler := routes(http.NotFoundHandler())
+	postLogin(handler, "10.0.0.1", "first", "pass")
+	postLogin(handler, "10.0.0.1", "second", "pass")
+	if w := postLogin(handler, "10.0.0.1", "third", "pass"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the third account in an hour to be refused, got %d", w.Code)
+	}
+	if exists, _ := db.UserExists("third"); exists {
+		t.Error("a refused signup should not create the account")
+	}
+	if w := postLogin(handler, "10.0.0.1", "first", "pass"); w.Code != http.StatusFound {
+		t.Errorf("existing accounts should still log in, got %d", w.Code)
+	}
+	if w := postLogin(handler, "10.0.0.2", "third", "pass"); w.Code != http.StatusFound {
+		t.Errorf("other addresses should still sign up, got %d", w.Code)
+	}
+	postLogin(handler, "10.0.0.1", "first", "pass")
+	if w := postLogin(handler, "10.0.0.1", "first", "pass"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected logins past the per minute limit to be refused, got %d", w.Code)
+	}
+}
+
+func TestBetRateLimit(t *testing.T) {
+	useTestDB(t)
+	useLimits(t, RateLimits{BetsPerMinute: 2})
+	handler := routes(http.NotFoundHandler())
+	userID, _ := db.CheckAddUser("gambler", "pass")
+	token := loginAs(t, userID, "gambler").Access
+
+	bet := func(ip string) int {
+		r := httptest.NewRequest(http.MethodPost, API_PREFIX+"/bets", strings.NewReader(`{"market":"duel","side":"left","amount":5}`))
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	bet("10.0.0.1")
+	bet("10.0.0.1")
+	if code := bet("10.0.0.2"); code != http.StatusTooManyRequests {
+		t.Errorf("expected bets to be limited per user across addresses, got %d", code)
+	}
+}
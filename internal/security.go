@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const CSRF_COOKIE = "csrf_token"
+const CSRF_HEADER = "X-CSRF-Token" // Sent by htmx, see static/js/csrf.js
+const CSRF_FIELD = "csrf_token"    // Hidden field of forms posted without htmx
+
+const csrfTokenKey string = "csrfToken"
+
+// How long browsers are told to only reach the site over https, two years
+const HSTS_MAX_AGE = "max-age=63072000; includeSubDomains"
+
+// Set with -production when the site is served over https, making cookies secure and sending HSTS
+var production bool
+
+// Cookie sent to browsers with the settings every cookie of the site shares
+func siteCookie(name string, value string, httpOnly bool, sameSite http.SameSite) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		HttpOnly: httpOnly,
+		Secure:   production,
+		SameSite: sameSite,
+		Path:     "/",
+		MaxAge:   int(SESSION_LIFETIME.Seconds()),
+	}
+}
+
+// Session the csrf token is tied to, empty for visitors who have not logged in
+func csrfSession(r *http.Request) string {
+	return sessionFromCookie(r)
+}
+
+func csrfMAC(nonce string, session string, key SigningKey) string {
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte("csrf|" + nonce + "|" + session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Random token signed together with the session, so it stops being accepted once the user logs in or out
+func newCSRFToken(session string) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	return encoded + "." + csrfMAC(encoded, session, signingKeys[0])
+}
+
+// Tokens signed with any of the signing keys are accepted, so pages open during a key rotation keep working
+func validCSRFToken(token string, session string) bool {
+	nonce, mac, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+	for _, key := range signingKeys {
+		if hmac.Equal([]byte(mac), []byte(csrfMAC(nonce, session, key))) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Whether a request could have been forged by another site
+
+Only requests changing state are checked. Clients sending an API key or a token in the Authorization header
+cannot be made to send it by another site, and API requests without the login cookies carry no credentials
+*/
+func needsCSRFCheck(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	if apiKeyFromRequest(r) != "" || r.Header.Get("Authorization") != "" {
+		return false
+	}
+	_, accessErr := r.Cookie(ACCESS_COOKIE)
+	_, refreshErr := r.Cookie(REFRESH_COOKIE)
+	return accessErr == nil || refreshErr == nil || !strings.HasPrefix(r.URL.Path, API_PREFIX)
+}
+
+// Token for forms rendered during the request
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey).(string)
+	return token
+}
+
+/*
+Give every browser a csrf token in a cookie and reject state changing requests that do not send it back
+
+The token is sent back in the X-CSRF-Token header or the csrf_token form field. Another site can make a browser
+send the cookie but cannot read it, so it cannot send the token
+*/
+func withCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := csrfSession(r)
+		token := ""
+		if cookie, err := r.Cookie(CSRF_COOKIE); err == nil && validCSRFToken(cookie.Value, session) {
+			token = cookie.Value
+		} else {
+			token = newCSRFToken(session)
+			http.SetCookie(w, siteCookie(CSRF_COOKIE, token, false, http.SameSiteStrictMode))
+		}
+
+		if needsCSRFCheck(r) {
+			sent := r.Header.Get(CSRF_HEADER)
+			if sent == "" {
+				sent = r.PostFormValue(CSRF_FIELD)
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				requestLogger(r).Info("Request refused without a valid csrf token", "origin", r.Header.Get("Origin"))
+				http.Error(w, "Invalid CSRF token, reload the page and try again", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), csrfTokenKey, token)))
+	})
+}
+
+// Tell browsers to only use https from now on, only sent in production
+func withHSTS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if production {
+			w.Header().Set("Strict-Transport-Security", HSTS_MAX_AGE)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Csrf token cookie handed out to a browser visiting the site with the given cookies
+func visit(t *testing.T, handler http.Handler, cookies ...*http.Cookie) *http.Cookie {
+	r := httptest.NewRequest(http.MethodGet, "/user/promptLogin", nil)
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == CSRF_COOKIE {
+			return cookie
+		}
+	}
+	t.Fatal("expected a csrf cookie to be set")
+	return nil
+}
+
+func TestCrossSiteRequestsRejected(t *testing.T) {
+	useTestDB(t)
+	handler := withCSRF(routes(http.NotFoundHandler()))
+	userID, _ := db.CheckAddUser("player", "pass")
+	tokens := loginAs(t, userID, "player")
+	access, refresh := sessionCookies(tokens)[0], sessionCookies(tokens)[1]
+	csrf := visit(t, handler, access, refresh)
+
+	post := func(path string, form url.Values, header string, cookies ...*http.Cookie) int {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set(CSRF_HEADER, header)
+		}
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	bet := url.Values{"betside": {"left"}, "betamount": {"5"}}
+
+	if code := post("/user/placeBet", bet, "", access, refresh, csrf); code != http.StatusForbidden {
+		t.Errorf("a cross-site form post should be rejected, got %d", code)
+	}
+	if code := post("/user/login", url.Values{"name": {"player"}, "pass": {"pass"}}, ""); code != http.StatusForbidden {
+		t.Errorf("a cross-site login should be rejected, got %d", code)
+	}
+	anonymous := visit(t, handler)
+	if code := post("/user/placeBet", bet, anonymous.Value, access, refresh, anonymous); code != http.StatusForbidden {
+		t.Errorf("a token from another session should be rejected, got %d", code)
+	}
+
+	if code := post("/user/placeBet", bet, csrf.Value, access, refresh, csrf); code == http.StatusForbidden {
+		t.Errorf("htmx requests sending the token in a header should be let through, got %d", code)
+	}
+	withField := url.Values{"betside": {"left"}, "betamount": {"5"}, CSRF_FIELD: {csrf.Value}}
+	if code := post("/user/placeBet", withField, "", access, refresh, csrf); code == http.StatusForbidden {
+		t.Errorf("forms sending the token in a field should be let through, got %d", code)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, API_PREFIX+"/bets", strings.NewReader(`{"market":"duel","side":"left","amount":5}`))
+	r.Header.Set("Authorization", "Bearer "+tokens.Access)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code == http.StatusForbidden {
+		t.Errorf("API clients sending their token in a header do not need a csrf token, got %d", w.Code)
+	}
+}
+
+func TestProductionCookies(t *testing.T) {
+	production = true
+	t.Cleanup(func() { production = false })
+	handler := withHSTS(withCSRF(http.NotFoundHandler()))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get("Strict-Transport-Security") == "" {
+		t.Error("expected HSTS to be sent in production")
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if !cookie.Secure {
+			t.Errorf("expected the %s cookie to be secure", cookie.Name)
+		}
+	}
+}
@@ -5,66 +5,63 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"strconv"
 
 	"fmt"
-	"js-bet/internal/assets"
+	"io"
 	"js-bet/internal/components"
 	"js-bet/internal/eventlog"
 	"js-bet/internal/game"
+	"js-bet/internal/metrics"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/andybalholm/brotli"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 const (
 	PORT = 8080
 )
 
-var homepage []byte
-var siteAssets assets.Assets
 var db DBClient
-var staticPath string
 var sseHub *Hub
 
+// Signs tokens when no signing keys are given, only meant for development
 const SECRET = "I am a secret key"
 
-func StartServer() {
-	// Get access to the filesystem
-	projectRoot, err := os.Getwd()
-	log.Print(projectRoot)
+func StartServer(cfg Config) {
+	startedAt = time.Now()
+	production = cfg.Production
+	limits = cfg.RateLimits
+	if len(cfg.SigningKeys) > 0 {
+		signingKeys = cfg.SigningKeys
+	} else if cfg.Production {
+		// Anyone could sign tokens with the development key, so it is never used in production
+		log.Fatalf("No signing keys given, set %s to run in production", SIGNING_KEYS_ENV)
+	}
+	staticFiles = openStatic(cfg.StaticDir)
+	loaded, err := loadStatic(staticFiles)
 	if err != nil {
-		log.Panic(err)
+		log.Panicf("Error loading static files: %v", err)
 	}
-	staticPath = filepath.Join(projectRoot, "static")
-	fileServer := http.FileServer(http.Dir(staticPath))
-
-	// Create new server Handler
-	mux := http.NewServeMux()
-	mux.Handle("/", fileServer)
-	mux.Handle("/game/", authMiddlewarePermissive(http.HandlerFunc(handleGame)))
-	mux.HandleFunc("/user/promptLogin", handlePromptLoginRequest)
-	// mux.HandleFunc("/user/new", handleNewUserRequest)
-	mux.HandleFunc("/user/login", handleLoginRequest)
-	// mux.HandleFunc("/user/gold", handleGetUserInfo)
-	mux.Handle("/user/placeBet", authMiddlewareStrict(http.HandlerFunc(handlePlaceBet)))
+	site.Store(loaded)
+	mux := routes(withCacheHeaders(http.FileServerFS(staticFiles)))
 
 	// Setup event log for server
 	eventlog.EventLog = eventlog.New()
 
-	siteAssets = assets.New()
-	siteAssets.ReadIcons(filepath.Join(staticPath, "icons"))
-
 	port := fmt.Sprintf(":%d", PORT)
 	s := &http.Server{
 		Addr:           port,
-		Handler:        mux,
+		Handler:        withRequestID(withRecover(withHSTS(withCSRF(withSessionRefresh(mux))))),
 		WriteTimeout:   time.Second * 5,
 		ReadTimeout:    time.Second * 5,
 		MaxHeaderBytes: 1 << 20,
@@ -74,22 +71,141 @@ func StartServer() {
 	if err = db.InitDB(); err != nil {
 		log.Panicf("Error initializing database: %v", err)
 	}
+	for _, name := range cfg.Admins {
+		if found, err := db.SetUserRole(name, ROLE_ADMIN); err != nil {
+			slog.Error("Unable to promote admin", "user", name, "err", err)
+		} else if !found {
+			slog.Warn("Unable to promote admin, no such user", "user", name)
+		}
+	}
 
-	log.Printf("Starting server on https://localhost:%d\n", PORT)
+	arenaLogger = slog.Default().With("arena", cfg.Arena)
+	staticSource := cfg.StaticDir
+	if staticSource == "" {
+		staticSource = "embedded"
+	}
+	slog.Info("Starting server", "port", PORT, "arena", cfg.Arena, "static", staticSource)
 
-	currentGame := game.New()
+	currentGame := newGame(cfg)
 
-	sseHub = NewHub()
+	sseHub = NewHub("game")
 	go sseHub.Run()
+	apiHub = NewHub("api")
+	go apiHub.Run()
 
 	// Start first game and run until server closes
 	go runGame(currentGame, sseHub)
 
-	if err := s.ListenAndServe(); err != nil {
+	if cfg.Dev {
+		// Stop the watchers before exiting so templ does not outlive the server
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		watching := make(chan struct{})
+		go func() {
+			defer close(watching)
+			if err := watchForDev(ctx, cfg.StaticDir); err != nil {
+				slog.Error("Unable to watch for changes", "err", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			<-watching
+			s.Close()
+		}()
+	}
+
+	if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Panic(err)
 	}
 }
 
+// Routes of the site and its API, fileServer serves everything else from the static directory
+func routes(fileServer http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	// Every route names its method so the mux answers anything else with a 405
+	mux.Handle("GET /", fileServer)
+	mux.HandleFunc("GET /{$}", handleHomepage)
+	mux.Handle("GET /game/", authMiddlewarePermissive(errorHandler(handleGame)))
+	mux.HandleFunc("GET /assets/cues.json", handleCues)
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /healthz", handleHealthz)
+	mux.HandleFunc("GET /readyz", handleReadyz)
+	mux.HandleFunc("GET /status", handleStatus)
+	mux.Handle("GET /user/promptLogin", errorHandler(handlePromptLoginRequest))
+	// mux.HandleFunc("/user/new", handleNewUserRequest)
+	mux.Handle("POST /user/login", errorHandler(handleLoginRequest))
+	mux.Handle("POST /user/logout", errorHandler(handleLogout))
+	mux.Handle("POST /user/logoutAll", authMiddlewareStrict(requireLogin(errorHandler(handleLogoutAll))))
+	// mux.HandleFunc("/user/gold", handleGetUserInfo)
+	mux.Handle("POST /user/placeBet", authMiddlewareStrict(requireScope(SCOPE_BET, limitBets(errorHandler(handlePlaceBet)))))
+	mux.Handle("POST /user/placeChampionBet", authMiddlewareStrict(requireScope(SCOPE_BET, limitBets(errorHandler(handlePlaceChampionBet)))))
+	mux.Handle("POST /user/placeRoyaleBet", authMiddlewareStrict(requireScope(SCOPE_BET, limitBets(errorHandler(handlePlaceRoyaleBet)))))
+	mux.HandleFunc("POST "+API_PREFIX+"/login", handleAPILogin)
+	mux.HandleFunc("POST "+API_PREFIX+"/refresh", handleAPIRefresh)
+	mux.Handle("POST "+API_PREFIX+"/logout", authMiddlewareStrict(requireLogin(http.HandlerFunc(handleAPILogout))))
+	mux.Handle("GET "+API_PREFIX+"/sessions", authMiddlewareStrict(requireLogin(http.HandlerFunc(handleAPISessions))))
+	mux.HandleFunc("GET "+API_PREFIX+"/state", handleAPIState)
+	mux.HandleFunc("GET "+API_PREFIX+"/stream", handleAPIStream)
+	mux.Handle("POST "+API_PREFIX+"/bets", authMiddlewareStrict(requireScope(SCOPE_BET, limitBets(http.HandlerFunc(handleAPIBets)))))
+	mux.Handle("GET "+API_PREFIX+"/me", authMiddlewareStrict(requireScope(SCOPE_READ, http.HandlerFunc(handleAPIMe))))
+	keys := authMiddlewareStrict(requireLogin(http.HandlerFunc(handleAPIKeys)))
+	mux.Handle("GET "+API_PREFIX+"/keys", keys)
+	mux.Handle("POST "+API_PREFIX+"/keys", keys)
+	mux.Handle("DELETE "+API_PREFIX+"/keys/{id}", authMiddlewareStrict(requireLogin(http.HandlerFunc(handleAPIKey))))
+	mux.HandleFunc("GET "+API_PREFIX+"/leaderboard", handleAPILeaderboard)
+	admin := authMiddlewareStrict(requireLogin(requireAdmin(http.HandlerFunc(handleAdmin))))
+	mux.Handle("GET /admin/{$}", admin)
+	mux.Handle("POST /admin/{action}", admin)
+	webhooks := authMiddlewareStrict(requireLogin(requireAdmin(http.HandlerFunc(handleAdminWebhooks))))
+	mux.Handle("GET "+API_PREFIX+"/admin/webhooks", webhooks)
+	mux.Handle("POST "+API_PREFIX+"/admin/webhooks", webhooks)
+	mux.Handle("DELETE "+API_PREFIX+"/admin/webhooks/{id}", authMiddlewareStrict(requireLogin(requireAdmin(http.HandlerFunc(handleDeleteWebhook)))))
+	mux.Handle("GET "+API_PREFIX+"/admin/webhooks/{id}/deliveries", authMiddlewareStrict(requireLogin(requireAdmin(http.HandlerFunc(handleWebhookDeliveries)))))
+	mux.Handle("POST "+API_PREFIX+"/admin/webhooks/{id}/test", authMiddlewareStrict(requireLogin(requireAdmin(http.HandlerFunc(handleTestWebhook)))))
+	return mux
+}
+
+// Create the first game, resuming an unfinished tournament from the database when running in tournament mode
+func newGame(cfg Config) game.GameState {
+	ratings, err := db.LoadFighterRatings()
+	if err != nil {
+		slog.Error("Unable to load fighter ratings", "err", err)
+	}
+	game.LoadRatings(ratings)
+
+	if cfg.RoyaleSize > 0 {
+		gs := game.NewBattleRoyale(cfg.RoyaleSize)
+		if cfg.Matchmaker != nil {
+			gs.Matchmaker = cfg.Matchmaker
+		}
+		return gs
+	}
+	if cfg.TournamentFormat == 0 {
+		gs := game.NewTeamBattle(max(cfg.TeamSize, 1))
+		if cfg.Matchmaker != nil {
+			gs.Matchmaker = cfg.Matchmaker
+		}
+		return gs
+	}
+	t, err := db.LoadActiveTournament(cfg.TournamentFormat)
+	if err != nil {
+		slog.Error("Unable to load tournament", "err", err)
+	}
+	if t != nil {
+		gs, err := game.NewTournamentGame(t)
+		if err == nil {
+			slog.Info("Resuming tournament", "tournament_id", t.ID)
+			return gs
+		}
+		slog.Error("Unable to resume tournament", "tournament_id", t.ID, "err", err)
+	}
+	gs, err := game.NewTournamentGame(game.NewTournament(cfg.TournamentFormat))
+	if err != nil {
+		log.Panic(err)
+	}
+	return gs
+}
+
 func runGame(gs game.GameState, hub *Hub) {
 	ticker := time.NewTicker(time.Millisecond * 1000)
 	defer ticker.Stop()
@@ -98,33 +214,77 @@ func runGame(gs game.GameState, hub *Hub) {
 	buffer.Grow(300)
 	w := bufio.NewWriter(&buffer)
 
-	for range ticker.C {
+	for {
+		select {
+		case command := <-arenaCommands:
+			command.done <- command.apply(&gs)
+			continue
+		case <-ticker.C:
+		}
+		tickStart := time.Now()
 		// If health of either combatant reaches 0, start a new game
 		buffer.Reset()
 
-		gs.StepGame()
-		if gs.Winner != game.NEITHER {
-			AwardBets(gs.Winner)
+		if !arenaPaused.Load() {
+			gs.StepGame()
 		}
+		dispatchLifecycleEvents(&gs)
+		publishState(gs, apiHub)
 
-		if len(sseHub.clients) > 0 {
-			// Render new gamestate into html for all clients
-			sides := components.FighterSides(gs, siteAssets)
-			err := sides.Render(context.TODO(), w)
-			if err != nil {
-				log.Panic(err)
+		if sseHub.HasClients() {
+			// A failed render skips this tick for the clients instead of stopping the game
+			if err := renderArena(&gs, w); err != nil {
+				arenaLogger.Error("Unable to render the arena", "err", err)
+			} else {
+				w.Flush()
+				var frame bytes.Buffer
+				WriteSSE(&frame, buffer.Bytes())
+				hub.broadcast <- frame.Bytes()
 			}
+		}
+		tickSeconds.Observe(time.Since(tickStart).Seconds())
+		recordTick(gs)
+	}
+}
 
-			events := components.EventLog(eventlog.EventLog)
-			err = events.Render(context.TODO(), w)
-			if err != nil {
-				log.Panic(err)
-			}
-			w.Flush()
-			hub.broadcast <- buffer.Bytes()
-			// log.Printf("RENDERED")
+// Render every component streamed to the game clients, a component that panics is reported as an error
+func renderArena(gs *game.GameState, w io.Writer) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("error: rendering panicked: %v", recovered)
 		}
+	}()
+	ctx := context.TODO()
+	if err = components.LifecycleBanner(banner.Text).Render(ctx, w); err != nil {
+		return err
 	}
+	if err = components.Announcement(currentAnnouncement(), arenaPaused.Load()).Render(ctx, w); err != nil {
+		return err
+	}
+
+	renderStart := time.Now()
+	if err = components.FighterSides(*gs, siteAssets()).Render(ctx, w); err != nil {
+		return err
+	}
+	renderSeconds.Observe(time.Since(renderStart).Seconds(), "FighterSides")
+
+	if gs.FreeForAll() {
+		if err = components.RoyaleBetForm(*gs, royaleBettingOpen()).Render(ctx, w); err != nil {
+			return err
+		}
+	}
+	if gs.Tournament != nil {
+		if err = components.TournamentBracket(gs.Tournament, championBettingOpen()).Render(ctx, w); err != nil {
+			return err
+		}
+	}
+
+	renderStart = time.Now()
+	if err = components.EventLog(eventlog.EventLog).Render(ctx, w); err != nil {
+		return err
+	}
+	renderSeconds.Observe(time.Since(renderStart).Seconds(), "EventLog")
+	return nil
 }
 
 /*
@@ -132,10 +292,10 @@ func runGame(gs game.GameState, hub *Hub) {
 	Attempts to serve the html with different forms of compression depending on the accepted content encodings of the client
 */
 
-func handleGame(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		log.Panic("Incorrect method for endpoint '/game/', expected POST")
-		return
+func handleGame(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("error: streaming not supported")
 	}
 
 	rc := http.NewResponseController(w)
@@ -151,11 +311,11 @@ func handleGame(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case strings.Contains(encodings, "br"):
 		w.Header().Set("Content-Encoding", "br")
-		brotliWriter = brotli.NewWriterOptions(w, brotli.WriterOptions{Quality: 5, LGWin: 24})
+		brotliWriter = brotli.NewWriterOptions(countingWriter{w, "br"}, brotli.WriterOptions{Quality: 5, LGWin: 24})
 	case strings.Contains(encodings, "gzip"):
 		w.Header().Set("Content-Encoding", "gzip")
 		var err error
-		gzipWriter, err = gzip.NewWriterLevel(w, 5)
+		gzipWriter, err = gzip.NewWriterLevel(countingWriter{w, "gzip"}, 5)
 		if err != nil {
 			gzipWriter = nil
 			break
@@ -167,13 +327,8 @@ func handleGame(w http.ResponseWriter, r *http.Request) {
 	sseHub.register <- client
 	defer func() { sseHub.unregister <- client }()
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
-		return
-	}
+	requestLogger(r).Debug("Game stream connected", "encoding", w.Header().Get("Content-Encoding"))
 	userId, foundUser := r.Context().Value("UserId").(string)
-	log.Printf("Userid found: %s", userId)
 	if foundUser {
 		// Populate userID in user->client map
 		userClientMap[userId] = client
@@ -182,87 +337,75 @@ func handleGame(w http.ResponseWriter, r *http.Request) {
 
 	for {
 		select {
-		case html, ok := <-client:
+		case frame, ok := <-client:
 			if !ok {
-				return
+				return nil
 			}
 			var writeErr error
 			if brotliWriter != nil {
-				log.Printf("Compressing with brotli\n")
-				writeErr = WriteSSE(brotliWriter, html)
+				_, writeErr = brotliWriter.Write(frame)
 				err := brotliWriter.Flush()
 				if err != nil {
-					fmt.Printf("error flushing writer %v", err)
+					requestLogger(r).Debug("Unable to flush game stream", "err", err)
 				}
 			} else if gzipWriter != nil {
-				log.Printf("Compressing with gzip\n")
-				writeErr = WriteSSE(gzipWriter, html)
+				_, writeErr = gzipWriter.Write(frame)
 				err := gzipWriter.Flush()
 				if err != nil {
-					fmt.Printf("error flushing writer %v", err)
+					requestLogger(r).Debug("Unable to flush game stream", "err", err)
 				}
 			} else {
-				writeErr = WriteSSE(w, html)
+				_, writeErr = countingWriter{w, "identity"}.Write(frame)
 			}
 			if writeErr != nil {
-				return
+				return nil
 			}
 			flusher.Flush()
 		case <-r.Context().Done():
-			return
+			return nil
 		}
 	}
 
 }
 
-func handleLoginRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		log.Panic("Incorrect method for endpoint 'user/login', expected POST")
-		return
+// Serves the resolved cue manifest so the client can preload every sound the game may play
+func handleCues(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(siteAssets().Cues); err != nil {
+		requestLogger(r).Debug("Unable to write cue manifest", "err", err)
+	}
+}
+
+func handleLoginRequest(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errorf(http.StatusBadRequest, "Unable to read the login form")
 	}
-	r.ParseForm()
 	userName := r.FormValue("name")
 	passWord := r.FormValue("pass")
 	w.Header().Set("Content-Type", "text/html")
 
-	userId, err := db.CheckAddUser(userName, passWord)
-	if err != nil {
-		_, err := fmt.Fprint(w, "<div> Unable to add or find user in the database</div>")
-		if err != nil {
-			log.Panic(err)
-		}
-		return
-	}
-
-	claims := UserClaims{
-		UserID:   userId,
-		Password: passWord,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "js.bet",
-			Subject:   fmt.Sprintf("%d", userId),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(SECRET)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "jwt_token",
-		Value:    signed,
-		HttpOnly: true,  // Prevents JavaScript access (XSS protection)
-		Secure:   false, // Set to true in production with HTTPS
-		SameSite: http.SameSiteLaxMode,
-		Path:     "/",
-		MaxAge:   86400, // 24 hours in seconds
-	})
-	http.Redirect(w, r, "/", http.StatusFound)
+	userId, err := login(r, userName, passWord)
+	var limitErr rateLimitError
+	if errors.As(err, &limitErr) {
+		logins.Inc("limited")
+		return err
+	} else if err != nil {
+		logins.Inc("failure")
+		return errorf(http.StatusUnauthorized, "Unable to add or find user in the database")
+	}
+	if banned, _ := db.IsUserBanned(userId); banned {
+		logins.Inc("banned")
+		return errorf(http.StatusForbidden, "This account has been banned")
+	}
+	logins.Inc("success")
 
-	_, err = fmt.Fprintf(w, "<div>Received user with name: %s, added to db!</div>", userName)
+	tokens, err := startSession(r, userId, userName)
 	if err != nil {
-		log.Panic(err)
-		return
+		return err
 	}
+	setSessionCookies(w, tokens)
+	http.Redirect(w, r, "/", http.StatusFound)
+	return nil
 }
 
 // func handleNewUserRequest(w http.ResponseWriter, r *http.Request) {
@@ -294,20 +437,10 @@ func handleLoginRequest(w http.ResponseWriter, r *http.Request) {
 // 	}
 // }
 
-func handlePromptLoginRequest(w http.ResponseWriter, r *http.Request) {
+func handlePromptLoginRequest(w http.ResponseWriter, r *http.Request) error {
 	// On a GET request, send a signup popup gui to the user
-	if r.Method != http.MethodGet {
-		log.Panic("Incorrect method for endpoint 'user/promptLogin', expected GET")
-		return
-	}
-
 	w.Header().Set("Content-Type", "text/html")
-	signup := components.PopupLogin()
-	err := signup.Render(context.Background(), w)
-	if err != nil {
-		log.Panic(err)
-		return
-	}
+	return components.PopupLogin(csrfToken(r)).Render(context.Background(), w)
 }
 
 // func handleGetUserInfo(w http.ResponseWriter, r *http.Request) {
@@ -331,14 +464,10 @@ func handlePromptLoginRequest(w http.ResponseWriter, r *http.Request) {
 // 	}
 // }
 
-func handlePlaceBet(w http.ResponseWriter, r *http.Request) {
+func handlePlaceBet(w http.ResponseWriter, r *http.Request) error {
 	// Show a popup temporarily to confirm the user has bet some amount
-	if r.Method != http.MethodPost {
-		return
-	}
-	r.ParseForm()
-	if r.Form == nil {
-		log.Panic("Unable to parse form")
+	if err := r.ParseForm(); err != nil {
+		return errorf(http.StatusBadRequest, "Unable to read the bet form")
 	}
 	betSide := r.FormValue("betside")
 
@@ -349,15 +478,60 @@ func handlePlaceBet(w http.ResponseWriter, r *http.Request) {
 	case "right":
 		isLeft = false
 	default:
-		log.Print("Bet side not found")
-		return
+		return errorf(http.StatusBadRequest, "Unknown bet side '%s'", betSide)
 	}
 
 	betAmount, err := strconv.Atoi(r.FormValue("betamount"))
 	if err != nil {
-		log.Print("Unable to determine bet amount from form values")
+		return errorf(http.StatusBadRequest, "Bet amount must be a whole number")
+	}
+	userName, _ := r.Context().Value(userNameKey).(string)
+	if err = SetBet(userName, betAmount, isLeft); err != nil {
+		return errorf(http.StatusBadRequest, "Unable to place bet: %v", err)
 	}
-	SetBet("TempUsername", betAmount, isLeft)
 	fmt.Fprintf(w, "Placed bet amount for $%d", betAmount)
+	return nil
+}
 
+func handlePlaceChampionBet(w http.ResponseWriter, r *http.Request) error {
+	// Place an outright bet on the tournament champion, only accepted before the tournament starts
+	if err := r.ParseForm(); err != nil {
+		return errorf(http.StatusBadRequest, "Unable to read the bet form")
+	}
+	userName, _ := r.Context().Value(userNameKey).(string)
+	fighter := r.FormValue("fighter")
+
+	betAmount, err := strconv.Atoi(r.FormValue("betamount"))
+	if err != nil {
+		return errorf(http.StatusBadRequest, "Bet amount must be a whole number")
+	}
+	if err = SetChampionBet(userName, betAmount, fighter); err != nil {
+		return errorf(http.StatusBadRequest, "Unable to place bet: %v", err)
+	}
+	fmt.Fprintf(w, "Placed bet amount for $%d on %s to win the tournament", betAmount, fighter)
+	return nil
+}
+
+func handlePlaceRoyaleBet(w http.ResponseWriter, r *http.Request) error {
+	// Bet on a fighter to be the last one standing or finish in the top three of a battle royale
+	if err := r.ParseForm(); err != nil {
+		return errorf(http.StatusBadRequest, "Unable to read the bet form")
+	}
+	userName, _ := r.Context().Value(userNameKey).(string)
+	fighter := r.FormValue("fighter")
+
+	kind, ok := parseRoyaleBetKind(r.FormValue("kind"))
+	if !ok {
+		return errorf(http.StatusBadRequest, "Unknown bet kind '%s'", r.FormValue("kind"))
+	}
+
+	betAmount, err := strconv.Atoi(r.FormValue("betamount"))
+	if err != nil {
+		return errorf(http.StatusBadRequest, "Bet amount must be a whole number")
+	}
+	if err = SetRoyaleBet(userName, betAmount, fighter, kind); err != nil {
+		return errorf(http.StatusBadRequest, "Unable to place bet: %v", err)
+	}
+	fmt.Fprintf(w, "Placed bet amount for $%d on %s", betAmount, fighter)
+	return nil
 }
@@ -0,0 +1,314 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// How long a session lasts after logging in, however often its tokens are refreshed
+const SESSION_LIFETIME = 30 * 24 * time.Hour
+
+const REFRESH_COOKIE = "refresh_token"
+
+/*
+How long a refresh token that was just replaced is still accepted
+
+Browsers often send several requests at once when their access token expires, all carrying the same refresh token.
+The first one rotates it and the others get an access token without a new refresh token. Past this grace period
+a replaced token being sent again means it was stolen, and the whole session is revoked
+*/
+const REFRESH_REUSE_GRACE = 30 * time.Second
+
+// Kept for every login so its tokens can be revoked, only hashes of its refresh tokens are stored
+type Session struct {
+	ID           string
+	UserID       int64
+	UserName     string
+	RefreshHash  string
+	PreviousHash string // Hash of the refresh token replaced at RotatedAt
+	UserAgent    string
+	CreatedAt    time.Time
+	RotatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// Tokens handed out when logging in or refreshing, Refresh is empty when the refresh token was not rotated
+type sessionTokens struct {
+	Access         string
+	AccessExpires  time.Time
+	Refresh        string
+	RefreshExpires time.Time
+}
+
+// Session as shown to its user, without the hashes of its tokens
+type SessionView struct {
+	CreatedAt time.Time `json:"createdAt"`
+	LastUsed  time.Time `json:"lastUsed"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	UserAgent string    `json:"userAgent"`
+	Current   bool      `json:"current"` // The session the request was made with
+}
+
+func randomToken() string {
+	token := make([]byte, 32)
+	rand.Read(token)
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Start a session for a user who just logged in
+func startSession(r *http.Request, userID int64, userName string) (sessionTokens, error) {
+	now := time.Now()
+	session := Session{
+		ID:        randomToken()[:22],
+		UserID:    userID,
+		UserName:  userName,
+		UserAgent: r.UserAgent(),
+		CreatedAt: now,
+		RotatedAt: now,
+		ExpiresAt: now.Add(SESSION_LIFETIME),
+	}
+	secret := randomToken()
+	if err := db.CreateSession(session, hashRefreshSecret(secret)); err != nil {
+		return sessionTokens{}, err
+	}
+	return sessionTokensFor(session, secret)
+}
+
+func sessionTokensFor(session Session, secret string) (sessionTokens, error) {
+	access, expires, err := issueAccessToken(session.UserID, session.UserName, session.ID)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+	tokens := sessionTokens{Access: access, AccessExpires: expires}
+	if secret != "" {
+		tokens.Refresh, tokens.RefreshExpires = session.ID+"."+secret, session.ExpiresAt
+	}
+	return tokens, nil
+}
+
+/*
+Trade a refresh token for a new access token and a new refresh token
+
+The refresh token is made of the session id and a secret, which is replaced every time it is used. A replaced
+secret is accepted once more within REFRESH_REUSE_GRACE, anything later revokes the session
+*/
+func refreshSession(refresh string) (sessionTokens, error) {
+	id, secret, found := strings.Cut(refresh, ".")
+	if !found {
+		return sessionTokens{}, errInvalidToken
+	}
+	session, err := db.FindSession(id)
+	if err != nil {
+		refreshes.Inc("invalid")
+		return sessionTokens{}, errSessionEnded
+	}
+	hash := hashRefreshSecret(secret)
+	now := time.Now()
+	switch {
+	case subtle.ConstantTimeCompare([]byte(hash), []byte(session.RefreshHash)) == 1:
+		next := randomToken()
+		rotated, err := db.RotateSession(id, hash, hashRefreshSecret(next), now)
+		if err != nil {
+			return sessionTokens{}, err
+		} else if !rotated {
+			// Another request rotated it in the meantime, this token is now the previous one
+			return refreshSession(refresh)
+		}
+		refreshes.Inc("rotated")
+		return sessionTokensFor(session, next)
+	case subtle.ConstantTimeCompare([]byte(hash), []byte(session.PreviousHash)) == 1:
+		if now.Sub(session.RotatedAt) <= REFRESH_REUSE_GRACE {
+			refreshes.Inc("grace")
+			return sessionTokensFor(session, "")
+		}
+		refreshes.Inc("reused")
+		slog.Warn("Replaced refresh token sent again, revoking the session", "user_id", session.UserID)
+		if err := db.RevokeSession(id); err != nil {
+			return sessionTokens{}, err
+		}
+		return sessionTokens{}, errSessionEnded
+	}
+	refreshes.Inc("invalid")
+	return sessionTokens{}, errInvalidToken
+}
+
+// Id of the session a browser is logged into, read from the refresh cookie which outlives the access token
+func sessionFromCookie(r *http.Request) string {
+	cookie, err := r.Cookie(REFRESH_COOKIE)
+	if err != nil {
+		return ""
+	}
+	id, _, _ := strings.Cut(cookie.Value, ".")
+	return id
+}
+
+// HttpOnly keeps the tokens away from scripts, Lax still sends them when following links to the site
+func setSessionCookies(w http.ResponseWriter, tokens sessionTokens) {
+	http.SetCookie(w, siteCookie(ACCESS_COOKIE, tokens.Access, true, http.SameSiteLaxMode))
+	if tokens.Refresh != "" {
+		http.SetCookie(w, siteCookie(REFRESH_COOKIE, tokens.Refresh, true, http.SameSiteLaxMode))
+	}
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{ACCESS_COOKIE, REFRESH_COOKIE} {
+		cookie := siteCookie(name, "", true, http.SameSiteLaxMode)
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+	}
+}
+
+// Replace the access cookie the request was sent with, so handlers see the token that was just refreshed
+func withAccessCookie(r *http.Request, access string) *http.Request {
+	cookies := r.Cookies()
+	r = r.Clone(r.Context())
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name != ACCESS_COOKIE {
+			r.AddCookie(cookie)
+		}
+	}
+	r.AddCookie(&http.Cookie{Name: ACCESS_COOKIE, Value: access})
+	return r
+}
+
+/*
+Renew the access cookie of browsers whose token expired, using their refresh cookie
+
+Clients sending their token in a header renew it themselves through the refresh endpoint. Browsers whose session
+ended get both cookies cleared so they show up as logged out
+*/
+func withSessionRefresh(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refresh, err := r.Cookie(REFRESH_COOKIE)
+		if err != nil || r.Header.Get("Authorization") != "" || apiKeyFromRequest(r) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if access, err := r.Cookie(ACCESS_COOKIE); err == nil {
+			if _, err := parseAccessToken(access.Value); err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		tokens, err := refreshSession(refresh.Value)
+		if errors.Is(err, errInvalidToken) || errors.Is(err, errSessionEnded) {
+			requestLogger(r).Info("Unable to refresh session", "err", err)
+			clearSessionCookies(w)
+			next.ServeHTTP(w, r)
+			return
+		} else if err != nil {
+			requestLogger(r).Error("Unable to refresh session", "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		setSessionCookies(w, tokens)
+		next.ServeHTTP(w, withAccessCookie(r, tokens.Access))
+	})
+}
+
+// Revoke the session of the browser and clear its cookies, works whether or not the access token is still valid
+func handleLogout(w http.ResponseWriter, r *http.Request) error {
+	if id := sessionFromCookie(r); id != "" {
+		if err := db.RevokeSession(id); err != nil {
+			return err
+		}
+	}
+	clearSessionCookies(w)
+	http.Redirect(w, r, "/", http.StatusFound)
+	return nil
+}
+
+// Revoke every session of the user, logging them out on all their devices
+func handleLogoutAll(w http.ResponseWriter, r *http.Request) error {
+	userID, _ := r.Context().Value(userIDKey).(int64)
+	revoked, err := db.RevokeUserSessions(userID)
+	if err != nil {
+		return err
+	}
+	requestLogger(r).Info("Logged out of every device", "sessions", revoked)
+	clearSessionCookies(w)
+	http.Redirect(w, r, "/", http.StatusFound)
+	return nil
+}
+
+type APIRefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type APILogoutRequest struct {
+	AllDevices bool `json:"allDevices"`
+}
+
+func handleAPIRefresh(w http.ResponseWriter, r *http.Request) {
+	var request APIRefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RefreshToken == "" {
+		writeAPIError(w, http.StatusBadRequest, "expected a refresh token")
+		return
+	}
+	tokens, err := refreshSession(request.RefreshToken)
+	if errors.Is(err, errInvalidToken) || errors.Is(err, errSessionEnded) {
+		writeAPIError(w, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		requestLogger(r).Error("Unable to refresh session", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to refresh session")
+		return
+	}
+	writeJSON(w, http.StatusOK, apiToken(tokens))
+}
+
+// End the session the request was made with, or every session of the user when asked to
+func handleAPILogout(w http.ResponseWriter, r *http.Request) {
+	var request APILogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid logout request")
+			return
+		}
+	}
+	userID, _ := r.Context().Value(userIDKey).(int64)
+	sessionID, _ := r.Context().Value(sessionIDKey).(string)
+	var err error
+	if request.AllDevices {
+		_, err = db.RevokeUserSessions(userID)
+	} else {
+		err = db.RevokeSession(sessionID)
+	}
+	if err != nil {
+		requestLogger(r).Error("Unable to log out", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to log out")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleAPISessions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(userIDKey).(int64)
+	current, _ := r.Context().Value(sessionIDKey).(string)
+	sessions, err := db.ListSessions(userID)
+	if err != nil {
+		requestLogger(r).Error("Unable to list sessions", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to list sessions")
+		return
+	}
+	views := make([]SessionView, len(sessions))
+	for i, session := range sessions {
+		views[i] = SessionView{session.CreatedAt, session.RotatedAt, session.ExpiresAt, session.UserAgent, session.ID == current}
+	}
+	writeJSON(w, http.StatusOK, views)
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Start a session for a user as if they had just logged in
+func loginAs(t *testing.T, userID int64, userName string) sessionTokens {
+	tokens, err := startSession(httptest.NewRequest(http.MethodPost, "/user/login", nil), userID, userName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+func sessionCookies(tokens sessionTokens) []*http.Cookie {
+	return []*http.Cookie{{Name: ACCESS_COOKIE, Value: tokens.Access}, {Name: REFRESH_COOKIE, Value: tokens.Refresh}}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	useTestDB(t)
+	userID, _ := db.CheckAddUser("player", "pass")
+	first := loginAs(t, userID, "player")
+
+	second, err := refreshSession(first.Refresh)
+	if err != nil || second.Refresh == "" || second.Refresh == first.Refresh {
+		t.Fatalf("expected the refresh token to be replaced, got %q (%v)", second.Refresh, err)
+	}
+	if racing, err := refreshSession(first.Refresh); err != nil || racing.Refresh != "" {
+		t.Errorf("a replaced token sent right away should only get an access token, got %+v (%v)", racing, err)
+	}
+
+	id, _, _ := strings.Cut(first.Refresh, ".")
+	db.conn.Exec(`UPDATE Sessions SET rotated_at = ? WHERE id = ?;`, time.Now().Add(-2*REFRESH_REUSE_GRACE).Unix(), id)
+	if _, err := refreshSession(first.Refresh); err != errSessionEnded {
+		t.Errorf("a replaced token sent after the grace period should end the session, got %v", err)
+	}
+	if _, err := refreshSession(second.Refresh); err == nil {
+		t.Error("the latest token should stop working once the session is revoked")
+	}
+	if _, err := refreshSession(id + ".forged"); err == nil {
+		t.Error("a token with the wrong secret should be refused")
+	}
+}
+
+func TestSessionRevocation(t *testing.T) {
+	useTestDB(t)
+	userID, _ := db.CheckAddUser("player", "pass")
+	handler := withCSRF(withSessionRefresh(routes(http.NotFoundHandler())))
+	phone, laptop, tablet := loginAs(t, userID, "player"), loginAs(t, userID, "player"), loginAs(t, userID, "player")
+
+	me := func(tokens sessionTokens) int {
+		r := httptest.NewRequest(http.MethodGet, API_PREFIX+"/me", nil)
+		r.Header.Set("Authorization", "Bearer "+tokens.Access)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	logout := func(tokens sessionTokens, body string) {
+		r := httptest.NewRequest(http.MethodPost, API_PREFIX+"/logout", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+tokens.Access)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected to log out, got %d: %s", w.Code, w.Body)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, API_PREFIX+"/sessions", nil)
+	r.Header.Set("Authorization", "Bearer "+phone.Access)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var sessions []SessionView
+	json.NewDecoder(w.Body).Decode(&sessions)
+	if len(sessions) != 3 {
+		t.Errorf("expected the three sessions to be listed, got %d", len(sessions))
+	}
+
+	logout(phone, "")
+	if code := me(phone); code != http.StatusUnauthorized {
+		t.Errorf("a token of a session that logged out should be refused, got %d", code)
+	}
+	if code := me(laptop); code != http.StatusOK {
+		t.Errorf("other sessions should stay logged in, got %d", code)
+	}
+	logout(laptop, `{"allDevices":true}`)
+	if code := me(tablet); code != http.StatusUnauthorized {
+		t.Errorf("logging out of every device should end every session, got %d", code)
+	}
+}
+
+func TestBrowserSessionRefresh(t *testing.T) {
+	useTestDB(t)
+	userID, _ := db.CheckAddUser("player", "pass")
+	handler := withCSRF(withSessionRefresh(routes(http.NotFoundHandler())))
+	tokens := loginAs(t, userID, "player")
+	expired := &http.Cookie{Name: ACCESS_COOKIE, Value: "expired"}
+	refresh := &http.Cookie{Name: REFRESH_COOKIE, Value: tokens.Refresh}
+
+	r := httptest.NewRequest(http.MethodGet, API_PREFIX+"/me", nil)
+	r.AddCookie(expired)
+	r.AddCookie(refresh)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the access cookie to be renewed in place, got %d", w.Code)
+	}
+	renewed := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		renewed[cookie.Name] = cookie
+	}
+	if renewed[ACCESS_COOKIE] == nil || renewed[REFRESH_COOKIE] == nil || renewed[REFRESH_COOKIE].Value == tokens.Refresh {
+		t.Fatal("expected both session cookies to be replaced")
+	}
+
+	// Logging out from the browser revokes the session even though the form only sends cookies
+	csrf := visit(t, handler, renewed[ACCESS_COOKIE], renewed[REFRESH_COOKIE])
+	r = httptest.NewRequest(http.MethodPost, "/user/logout", nil)
+	r.Header.Set(CSRF_HEADER, csrf.Value)
+	for _, cookie := range []*http.Cookie{renewed[ACCESS_COOKIE], renewed[REFRESH_COOKIE], csrf} {
+		r.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected to be logged out, got %d", w.Code)
+	}
+	if _, err := refreshSession(renewed[REFRESH_COOKIE].Value); err == nil {
+		t.Error("expected the session to be revoked on logout")
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	useTestDB(t)
+	userID, _ := db.CheckAddUser("player", "pass")
+	previous := signingKeys
+	t.Cleanup(func() { signingKeys = previous })
+
+	old, err := ParseSigningKeys("2025:" + strings.Repeat("a", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKeys = old
+	tokens := loginAs(t, userID, "player")
+
+	signingKeys, err = ParseSigningKeys("2026:" + strings.Repeat("b", 32) + ", 2025:" + strings.Repeat("a", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseAccessToken(tokens.Access); err != nil {
+		t.Errorf("tokens signed with the previous key should still be accepted, got %v", err)
+	}
+	rotated, _ := refreshSession(tokens.Refresh)
+	if _, err := parseAccessToken(rotated.Access); err != nil {
+		t.Errorf("expected new tokens to be signed with the new key, got %v", err)
+	}
+
+	signingKeys = signingKeys[:1]
+	if _, err := parseAccessToken(tokens.Access); err == nil {
+		t.Error("tokens signed with a dropped key should be refused")
+	}
+
+	for _, bad := range []string{"nosecret", "short:secret", "a:" + strings.Repeat("a", 32) + ",a:" + strings.Repeat("b", 32)} {
+		if _, err := ParseSigningKeys(bad); err == nil {
+			t.Errorf("expected %q to be refused", bad)
+		}
+	}
+}
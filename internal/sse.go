@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 )
 
@@ -29,3 +30,21 @@ func WriteSSE(w io.Writer, html []byte) error {
 	}
 	return nil
 }
+
+// Write an event with a name, which clients dispatch as a DOM event of that name instead of swapping it in
+func WriteSSENamedEvent(w io.Writer, event string, data []byte) error {
+	_, err := fmt.Fprintf(w, "event: %s\n", event)
+	if err != nil {
+		return err
+	}
+	return WriteSSE(w, data)
+}
+
+// Write an event tagged with an id, which clients send back in the Last-Event-ID header when reconnecting
+func WriteSSEEvent(w io.Writer, id int64, data []byte) error {
+	_, err := fmt.Fprintf(w, "id: %d\n", id)
+	if err != nil {
+		return err
+	}
+	return WriteSSE(w, data)
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"io/fs"
+	"js-bet/internal/assets"
+	"js-bet/internal/game"
+	"js-bet/static"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+)
+
+// How long browsers keep files requested with their current fingerprint, a year as they can never change
+const FINGERPRINT_MAX_AGE = "max-age=31536000, immutable"
+
+// Files served by the site, embedded in the binary unless a directory is given with -static-dir
+var staticFiles fs.FS = static.Files
+
+// Assets and homepage loaded from the static files, replaced as a whole when they are reloaded during development
+type staticSite struct {
+	Assets   assets.Assets
+	Homepage []byte
+}
+
+var site atomic.Pointer[staticSite]
+
+func siteAssets() assets.Assets {
+	if loaded := site.Load(); loaded != nil {
+		return loaded.Assets
+	}
+	return assets.New()
+}
+
+func openStatic(dir string) fs.FS {
+	if dir == "" {
+		return static.Files
+	}
+	return os.DirFS(dir)
+}
+
+// Read the icons, cues and homepage from the static files and fingerprint the files they link to
+func loadStatic(fsys fs.FS) (*staticSite, error) {
+	loaded := assets.New()
+	if err := loaded.ReadIcons(fsys, "icons"); err != nil {
+		return nil, err
+	}
+	if missing := loaded.FillMissingIcons(game.RosterColors()); len(missing) > 0 {
+		slog.Warn("Fighters without a usable icon, showing placeholders", "fighters", missing)
+	}
+	if err := loaded.Fingerprint(fsys); err != nil {
+		return nil, err
+	}
+	loaded.LoadCues(fsys, game.AbilityCues())
+	page, err := fs.ReadFile(fsys, "index.html")
+	if err != nil {
+		return nil, err
+	}
+	return &staticSite{loaded, loaded.RewriteURLs(page)}, nil
+}
+
+func handleHomepage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if loaded := site.Load(); loaded != nil {
+		w.Write(loaded.Homepage)
+	}
+}
+
+// Let browsers keep fingerprinted files for good and revalidate everything else
+func withCacheHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if siteAssets().IsCurrent(r.URL.Path, r.URL.Query().Get("v")) {
+			w.Header().Set("Cache-Control", "public, "+FINGERPRINT_MAX_AGE)
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
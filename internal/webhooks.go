@@ -0,0 +1,357 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"js-bet/internal/game"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Events sent to webhooks
+const (
+	WEBHOOK_ROUND_STARTED = "round.started"
+	WEBHOOK_ROUND_ENDED   = "round.ended"
+	WEBHOOK_BIG_WIN       = "bet.big_win"
+	WEBHOOK_PING          = "ping" // Only sent by the test endpoint
+)
+
+var webhookEvents = []string{WEBHOOK_ROUND_STARTED, WEBHOOK_ROUND_ENDED, WEBHOOK_BIG_WIN}
+
+// Winnings of a single bet from which the win is announced to webhooks
+const BIG_WIN_THRESHOLD = 100
+
+// Deliveries are attempted this many times, waiting webhookBackoff after the first failure and twice as long after each next one
+const WEBHOOK_ATTEMPTS = 5
+
+var webhookBackoff = 2 * time.Second
+var webhookClient = &http.Client{Timeout: 5 * time.Second}
+
+// How long the test endpoint waits on the receiver, well within the server's write timeout so the admin gets an answer
+var webhookTestTimeout = 3 * time.Second
+
+/*
+URL registered by an admin to receive round events as JSON POSTs
+
+Every request is signed with the webhook's secret so receivers can check it came from the arena, the
+X-JSBet-Signature header holds "sha256=" followed by the hex HMAC-SHA256 of the X-JSBet-Timestamp header,
+a dot and the body
+*/
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // Only sent once, in the response registering the webhook
+	Events    []string  `json:"events"`           // Events the webhook receives, every event when empty
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (w Webhook) Subscribed(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// A single attempt at delivering an event to a webhook
+type WebhookDelivery struct {
+	ID          int64     `json:"id"`
+	WebhookID   int64     `json:"webhookId"`
+	Event       string    `json:"event"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"statusCode"` // Zero when no response was received
+	Error       string    `json:"error"`
+	DeliveredAt time.Time `json:"deliveredAt"`
+}
+
+type webhookPayload struct {
+	Event  string    `json:"event"`
+	SentAt time.Time `json:"sentAt"`
+	Data   any       `json:"data"`
+}
+
+type WebhookTeam struct {
+	Fighters  []string `json:"fighters"`
+	Rating    int      `json:"rating"`    // Average rating of the fighters
+	WinChance float64  `json:"winChance"` // Chance of the team winning according to the ratings
+}
+
+type RoundStarted struct {
+	Royale       bool          `json:"royale"`
+	Teams        []WebhookTeam `json:"teams"`
+	TournamentID int64         `json:"tournamentId,omitempty"`
+}
+
+type RoundEnded struct {
+	Winner     []string   `json:"winner"`
+	Placements [][]string `json:"placements"` // Fighters of every team, winners first
+	Status     string     `json:"status"`
+}
+
+type BigWin struct {
+	User   string `json:"user"`
+	Market string `json:"market"`
+	Amount int    `json:"amount"`
+}
+
+func newRoundStarted(gs game.GameState) RoundStarted {
+	started := RoundStarted{Royale: gs.FreeForAll()}
+	chances := gs.WinChances()
+	for i, team := range gs.Teams {
+		started.Teams = append(started.Teams, WebhookTeam{
+			Fighters:  team.Names(),
+			Rating:    team.AverageRating(),
+			WinChance: chances[i],
+		})
+	}
+	if gs.Tournament != nil {
+		started.TournamentID = gs.Tournament.ID
+	}
+	return started
+}
+
+func newRoundEnded(gs game.GameState) RoundEnded {
+	ended := RoundEnded{Status: gs.Status}
+	for i := len(gs.Placements) - 1; i >= 0; i-- {
+		ended.Placements = append(ended.Placements, gs.Teams[gs.Placements[i]].Names())
+	}
+	if winner := gs.WinningTeam(); winner != -1 {
+		ended.Winner = gs.Teams[winner].Names()
+	}
+	return ended
+}
+
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Make a single attempt at delivering a payload, a response outside of the 2xx range counts as a failure
+func sendWebhook(ctx context.Context, hook Webhook, event string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "js.bet-webhooks")
+	req.Header.Set("X-JSBet-Event", event)
+	req.Header.Set("X-JSBet-Timestamp", timestamp)
+	req.Header.Set("X-JSBet-Signature", signWebhook(hook.Secret, timestamp, body))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("error: webhook responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Record an attempt in the delivery log, returns whether it succeeded
+func attemptWebhook(ctx context.Context, hook Webhook, event string, body []byte, attempt int) bool {
+	status, err := sendWebhook(ctx, hook, event, body)
+	delivery := WebhookDelivery{
+		WebhookID:   hook.ID,
+		Event:       event,
+		Attempt:     attempt,
+		StatusCode:  status,
+		DeliveredAt: time.Now(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if logErr := db.LogWebhookDelivery(delivery); logErr != nil {
+		slog.Error("Unable to log webhook delivery", "webhook_id", hook.ID, "err", logErr)
+	}
+	return err == nil
+}
+
+func deliverWebhook(hook Webhook, event string, body []byte) {
+	backoff := webhookBackoff
+	for attempt := 1; attempt <= WEBHOOK_ATTEMPTS; attempt++ {
+		if attemptWebhook(context.Background(), hook, event, body, attempt) {
+			return
+		}
+		if attempt < WEBHOOK_ATTEMPTS {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	slog.Warn("Giving up on webhook delivery", "webhook_id", hook.ID, "event", event, "attempts", WEBHOOK_ATTEMPTS)
+}
+
+func encodeWebhookPayload(event string, data any) ([]byte, error) {
+	return json.Marshal(webhookPayload{Event: event, SentAt: time.Now(), Data: data})
+}
+
+// Send an event to every webhook subscribed to it, deliveries are retried in the background
+func emitWebhook(event string, data any) {
+	hooks, err := db.ListWebhooks()
+	if err != nil {
+		slog.Error("Unable to load webhooks", "err", err)
+		return
+	}
+	var body []byte
+	for _, hook := range hooks {
+		if !hook.Subscribed(event) {
+			continue
+		}
+		if body == nil {
+			if body, err = encodeWebhookPayload(event, data); err != nil {
+				slog.Error("Unable to encode webhook", "event", event, "err", err)
+				return
+			}
+		}
+		go deliverWebhook(hook, event, body)
+	}
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // Every event when empty
+}
+
+// List and register webhooks, only open to admins
+func handleAdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		hooks, err := db.ListWebhooks()
+		if err != nil {
+			requestLogger(r).Error("Unable to list webhooks", "err", err)
+			writeAPIError(w, http.StatusInternalServerError, "unable to list webhooks")
+			return
+		}
+		for i := range hooks {
+			hooks[i].Secret = ""
+		}
+		writeJSON(w, http.StatusOK, hooks)
+	case http.MethodPost:
+		var request WebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "expected a url and events")
+			return
+		}
+		if target, err := url.Parse(request.URL); err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			writeAPIError(w, http.StatusBadRequest, "url must be an http or https url")
+			return
+		}
+		for _, event := range request.Events {
+			if !slices.Contains(webhookEvents, event) {
+				writeAPIError(w, http.StatusBadRequest, "events must be any of "+strings.Join(webhookEvents, ", "))
+				return
+			}
+		}
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			requestLogger(r).Error("Unable to generate webhook secret", "err", err)
+			writeAPIError(w, http.StatusInternalServerError, "unable to register webhook")
+			return
+		}
+		hook := Webhook{URL: request.URL, Secret: secret, Events: request.Events, CreatedAt: time.Now()}
+		if err = db.CreateWebhook(&hook); err != nil {
+			requestLogger(r).Error("Unable to store webhook", "err", err)
+			writeAPIError(w, http.StatusInternalServerError, "unable to register webhook")
+			return
+		}
+		writeJSON(w, http.StatusCreated, hook)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "expected GET or POST")
+	}
+}
+
+// Id of the webhook named in the path, answering with a 400 when it is not a number
+func webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	hookID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "expected a webhook id")
+		return 0, false
+	}
+	return hookID, true
+}
+
+// Remove a webhook, served under DELETE /api/v1/admin/webhooks/{id}
+func handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hookID, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	deleted, err := db.DeleteWebhook(hookID)
+	if err != nil {
+		requestLogger(r).Error("Unable to delete webhook", "webhook_id", hookID, "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to delete webhook")
+		return
+	}
+	if !deleted {
+		writeAPIError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Latest delivery attempts of a webhook, served under GET /api/v1/admin/webhooks/{id}/deliveries
+func handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	hookID, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	deliveries, err := db.ListWebhookDeliveries(hookID, 50)
+	if err != nil {
+		requestLogger(r).Error("Unable to list webhook deliveries", "webhook_id", hookID, "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to list deliveries")
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// Send a webhook a ping right away and report whether it was received, served under POST /api/v1/admin/webhooks/{id}/test
+func handleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	hookID, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	hooks, err := db.ListWebhooks()
+	if err != nil {
+		requestLogger(r).Error("Unable to load webhooks", "err", err)
+		writeAPIError(w, http.StatusInternalServerError, "unable to load webhook")
+		return
+	}
+	index := slices.IndexFunc(hooks, func(hook Webhook) bool { return hook.ID == hookID })
+	if index == -1 {
+		writeAPIError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	body, err := encodeWebhookPayload(WEBHOOK_PING, map[string]string{"message": "Test delivery from js.bet"})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "unable to encode ping")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), webhookTestTimeout)
+	defer cancel()
+	delivered := attemptWebhook(ctx, hooks[index], WEBHOOK_PING, body, 1)
+	deliveries, _ := db.ListWebhookDeliveries(hookID, 1)
+	status := http.StatusOK
+	if !delivered {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, deliveries)
+}
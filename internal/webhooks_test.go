@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Receiver answering every delivery with the next status code, 200 once they run out
+type webhookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rec *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, r)
+	rec.bodies = append(rec.bodies, body)
+	status := http.StatusOK
+	if len(rec.statuses) > 0 {
+		status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rec *webhookReceiver) received() int {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return len(rec.requests)
+}
+
+// The i-th delivery received along with its body
+func (rec *webhookReceiver) delivery(i int) (*http.Request, []byte) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.requests[i], rec.bodies[i]
+}
+
+func registerWebhook(t *testing.T, target string, events ...string) Webhook {
+	body, _ := json.Marshal(WebhookRequest{URL: target, Events: events})
+	w := httptest.NewRecorder()
+	handleAdminWebhooks(w, httptest.NewRequest(http.MethodPost, API_PREFIX+"/admin/webhooks", strings.NewReader(string(body))))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected the webhook to be registered, got %d: %s", w.Code, w.Body)
+	}
+	var hook Webhook
+	if err := json.NewDecoder(w.Body).Decode(&hook); err != nil {
+		t.Fatal(err)
+	}
+	if hook.Secret == "" {
+		t.Fatal("the secret should be sent when registering a webhook")
+	}
+	return hook
+}
+
+func testWebhookRequest(hook Webhook) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("%s/admin/webhooks/%d/test", API_PREFIX, hook.ID), nil)
+	r.SetPathValue("id", strconv.FormatInt(hook.ID, 10))
+	return r
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	useTestDB(t)
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	hook := registerWebhook(t, server.URL, WEBHOOK_ROUND_ENDED)
+	emitWebhook(WEBHOOK_ROUND_STARTED, RoundStarted{})
+	emitWebhook(WEBHOOK_ROUND_ENDED, RoundEnded{Winner: []string{"React"}, Status: "React wins!"})
+	// Deliveries are logged once the receiver answered, so the log says when the event has arrived
+	var deliveries []WebhookDelivery
+	deadline := time.Now().Add(2 * time.Second)
+	for len(deliveries) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		var err error
+		if deliveries, err = db.ListWebhookDeliveries(hook.ID, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != WEBHOOK_ROUND_ENDED || receiver.received() != 1 {
+		t.Fatalf("expected only the subscribed event to be delivered, got %+v with %d deliveries", deliveries, receiver.received())
+	}
+
+	r, body := receiver.delivery(0)
+	if event := r.Header.Get("X-JSBet-Event"); event != WEBHOOK_ROUND_ENDED {
+		t.Errorf("expected a %s event, got %q", WEBHOOK_ROUND_ENDED, event)
+	}
+	if signature := signWebhook(hook.Secret, r.Header.Get("X-JSBet-Timestamp"), body); r.Header.Get("X-JSBet-Signature") != signature {
+		t.Errorf("signature %q does not match the body, expected %q", r.Header.Get("X-JSBet-Signature"), signature)
+	}
+	var payload struct {
+		Event string     `json:"event"`
+		Data  RoundEnded `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != WEBHOOK_ROUND_ENDED || len(payload.Data.Winner) != 1 || payload.Data.Winner[0] != "React" {
+		t.Errorf("unexpected payload %s", body)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	useTestDB(t)
+	previous := webhookBackoff
+	webhookBackoff = time.Millisecond
+	t.Cleanup(func() { webhookBackoff = previous })
+
+	receiver := &webhookReceiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	hook := registerWebhook(t, server.URL)
+
+	deliverWebhook(hook, WEBHOOK_BIG_WIN, []byte(`{}`))
+	deliveries, err := db.ListWebhookDeliveries(hook.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("expected a failed attempt and a retry, got %+v", deliveries)
+	}
+	attempts := map[int]int{}
+	for _, delivery := range deliveries {
+		attempts[delivery.Attempt] = delivery.StatusCode
+	}
+	if attempts[1] != http.StatusServiceUnavailable || attempts[2] != http.StatusOK {
+		t.Errorf("expected the first attempt to fail and the second to succeed, got %v", attempts)
+	}
+
+	w := httptest.NewRecorder()
+	handleTestWebhook(w, testWebhookRequest(hook))
+	if w.Code != http.StatusOK || receiver.received() != 3 {
+		t.Errorf("expected the test endpoint to ping the webhook, got %d with %d deliveries", w.Code, receiver.received())
+	}
+}
+
+func TestWebhookTestTimesOut(t *testing.T) {
+	useTestDB(t)
+	previous := webhookTestTimeout
+	webhookTestTimeout = 10 * time.Millisecond
+	t.Cleanup(func() { webhookTestTimeout = previous })
+
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	hook := registerWebhook(t, server.URL)
+
+	w := httptest.NewRecorder()
+	handleTestWebhook(w, testWebhookRequest(hook))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected a slow receiver to be reported as failing, got %d", w.Code)
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Go client for the js.bet JSON API, for bots and other programs placing bets without a browser
+
+	c := client.New("http://localhost:8080")
+	if err := c.Login(ctx, "bot", "password"); err != nil {
+		log.Fatal(err)
+	}
+	states := make(chan client.State)
+	go c.Stream(ctx, states)
+	for state := range states {
+		...
+	}
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const API_PREFIX = "/api/v1"
+
+// Tokens are renewed this long before they expire so requests in flight don't fail
+const TOKEN_MARGIN = time.Minute
+
+type Client struct {
+	BaseURL        string
+	HTTPClient     *http.Client
+	ReconnectDelay time.Duration // Delay before the first reconnect of the stream, doubled after every failed attempt
+	MaxDelay       time.Duration // Upper bound of the reconnect delay
+	OnStreamError  func(error)   // Called with the error that dropped the stream before reconnecting, may be nil
+
+	mu        sync.Mutex
+	apiKey    string
+	name      string
+	password  string
+	token     string
+	refresh   string
+	expiresAt time.Time
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:        strings.TrimSuffix(baseURL, "/"),
+		HTTPClient:     &http.Client{},
+		ReconnectDelay: time.Second,
+		MaxDelay:       30 * time.Second,
+	}
+}
+
+// Returned for every response with an error status, carrying the message sent by the server
+type APIError struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("js.bet api: %d %s", e.StatusCode, e.Message)
+}
+
+/*
+Log in, creating the account when the name is not taken yet
+
+The token is renewed with the refresh token before it expires, the credentials are kept to log in again when the
+session ends
+*/
+func (c *Client) Login(ctx context.Context, name string, password string) error {
+	c.mu.Lock()
+	c.name, c.password, c.refresh = name, password, ""
+	c.mu.Unlock()
+	return c.renewToken(ctx)
+}
+
+// End the session of the client, or every session of the account when allDevices is set
+func (c *Client) Logout(ctx context.Context, allDevices bool) error {
+	err := c.authorized(ctx, http.MethodPost, "/logout", map[string]bool{"allDevices": allDevices}, nil)
+	c.mu.Lock()
+	c.name, c.password, c.token, c.refresh = "", "", "", ""
+	c.mu.Unlock()
+	return err
+}
+
+// Authorize requests with an API key instead of logging in, keys never need to be renewed
+func (c *Client) UseAPIKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apiKey = key
+}
+
+// Trade the refresh token for a new token, logging in again when there is none or the session has ended
+func (c *Client) renewToken(ctx context.Context) error {
+	c.mu.Lock()
+	login := map[string]string{"name": c.name, "password": c.password}
+	refresh := c.refresh
+	c.mu.Unlock()
+
+	var t token
+	err := fmt.Errorf("js.bet api: no refresh token")
+	if refresh != "" {
+		err = c.do(ctx, http.MethodPost, "/refresh", "", map[string]string{"refreshToken": refresh}, &t)
+	}
+	if err != nil {
+		if err = c.do(ctx, http.MethodPost, "/login", "", login, &t); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	c.token, c.expiresAt = t.Token, t.ExpiresAt
+	if t.RefreshToken != "" {
+		c.refresh = t.RefreshToken
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// Token for the Authorization header, the API key when one is used or else the login token renewed when about to expire
+func (c *Client) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	apiKey, token, expiresAt, name := c.apiKey, c.token, c.expiresAt, c.name
+	c.mu.Unlock()
+	if apiKey != "" {
+		return apiKey, nil
+	}
+	if name == "" {
+		return "", fmt.Errorf("js.bet api: not logged in")
+	}
+	if token == "" || time.Until(expiresAt) < TOKEN_MARGIN {
+		if err := c.renewToken(ctx); err != nil {
+			return "", err
+		}
+		c.mu.Lock()
+		token = c.token
+		c.mu.Unlock()
+	}
+	return token, nil
+}
+
+// Send a request to an endpoint of the API, encoding body and decoding the response into out when they are not nil
+func (c *Client) do(ctx context.Context, method string, path string, token string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+API_PREFIX+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil {
+			apiErr.Message = resp.Status
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) authorized(ctx context.Context, method string, path string, body any, out any) error {
+	token, err := c.Token(ctx)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, method, path, token, body, out)
+}
+
+// Current state of the game, for clients that poll instead of streaming
+func (c *Client) State(ctx context.Context) (State, error) {
+	var state State
+	err := c.do(ctx, http.MethodGet, "/state", "", nil, &state)
+	return state, err
+}
+
+// Account of the logged in user, along with the bets still waiting on a result
+func (c *Client) Me(ctx context.Context) (User, error) {
+	var user User
+	err := c.authorized(ctx, http.MethodGet, "/me", nil, &user)
+	return user, err
+}
+
+func (c *Client) Balance(ctx context.Context) (int, error) {
+	user, err := c.Me(ctx)
+	return user.Gold, err
+}
+
+// Place a bet, replacing any earlier bet of the user on the same market
+func (c *Client) PlaceBet(ctx context.Context, bet Bet) (Bet, error) {
+	var placed Bet
+	err := c.authorized(ctx, http.MethodPost, "/bets", bet, &placed)
+	return placed, err
+}
+
+// Bet on the left or right side of a duel
+func (c *Client) BetOnSide(ctx context.Context, left bool, amount int) (Bet, error) {
+	side := "right"
+	if left {
+		side = "left"
+	}
+	return c.PlaceBet(ctx, Bet{Market: DUEL, Side: side, Amount: amount})
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamResumes(t *testing.T) {
+	resumedFrom := make(chan string, 1)
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connections += 1
+		w.Header().Set("Content-Type", "text/event-stream")
+		if connections == 1 {
+			// Drop the connection after two states
+			fmt.Fprint(w, "id: 1\ndata: {\"frame\":1}\n\nid: 2\ndata: {\"frame\":2}\n\n")
+			return
+		}
+		resumedFrom <- r.Header.Get("Last-Event-ID")
+		fmt.Fprint(w, "id: 3\ndata: {\"frame\":3}\n\n")
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.ReconnectDelay = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	states := make(chan State)
+	go c.Stream(ctx, states)
+
+	for frame := 1; frame <= 3; frame++ {
+		state := <-states
+		if state.Frame != frame {
+			t.Fatalf("expected frame %d, got %d", frame, state.Frame)
+		}
+	}
+	if id := <-resumedFrom; id != "2" {
+		t.Errorf("expected the stream to resume after event 2, got %q", id)
+	}
+}
+
+func TestTokenRenewal(t *testing.T) {
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case API_PREFIX + "/login":
+			logins += 1
+			// The first token is already about to expire
+			expires := time.Now().Add(time.Duration(logins-1) * time.Hour)
+			json.NewEncoder(w).Encode(token{Token: fmt.Sprintf("token-%d", logins), ExpiresAt: expires})
+		case API_PREFIX + "/me":
+			if r.Header.Get("Authorization") != "Bearer token-2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid token"})
+				return
+			}
+			json.NewEncoder(w).Encode(User{ID: 1, Name: "bot", Gold: 42})
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	ctx := context.Background()
+	if err := c.Login(ctx, "bot", "password"); err != nil {
+		t.Fatal(err)
+	}
+	gold, err := c.Balance(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gold != 42 || logins != 2 {
+		t.Errorf("expected 42 gold after renewing the token once, got %d gold after %d logins", gold, logins)
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	logins, refreshes := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case API_PREFIX + "/login":
+			logins += 1
+			json.NewEncoder(w).Encode(token{"token-1", time.Now(), "refresh-1"})
+		case API_PREFIX + "/refresh":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			refreshes += 1
+			if body["refreshToken"] != "refresh-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "session has ended"})
+				return
+			}
+			json.NewEncoder(w).Encode(token{"token-2", time.Now().Add(time.Hour), "refresh-2"})
+		case API_PREFIX + "/me":
+			json.NewEncoder(w).Encode(User{ID: 1, Name: "bot", Gold: 42})
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	ctx := context.Background()
+	if err := c.Login(ctx, "bot", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 || refreshes != 1 || c.refresh != "refresh-2" {
+		t.Errorf("expected the token to be refreshed instead of logging in again, got %d logins and %d refreshes", logins, refreshes)
+	}
+
+	// The server refuses refresh-2, so the client logs in again
+	c.expiresAt = time.Now()
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 2 {
+		t.Errorf("expected to log in again once the session ended, got %d logins", logins)
+	}
+}
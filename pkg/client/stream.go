@@ -0,0 +1,105 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Largest event accepted from the stream, states of big tournaments run to a few dozen kilobytes
+const MAX_EVENT_SIZE = 1 << 20
+
+/*
+Send the state of every tick to states until ctx is done, then close states and return the context's error
+
+Dropped connections are reopened with an exponential backoff, passing the id of the last event received as
+Last-Event-ID so the server replays the states missed in between
+*/
+func (c *Client) Stream(ctx context.Context, states chan<- State) error {
+	defer close(states)
+	var lastID string
+	delay := c.ReconnectDelay
+	for {
+		received, err := c.streamOnce(ctx, &lastID, states)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			delay = c.ReconnectDelay
+		}
+		if c.OnStreamError != nil {
+			c.OnStreamError(err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay = min(delay*2, c.MaxDelay)
+	}
+}
+
+// Read events from a single connection until it fails, reporting whether any state was received
+func (c *Client) streamOnce(ctx context.Context, lastID *string, states chan<- State) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+API_PREFIX+"/stream", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+
+	received := false
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), MAX_EVENT_SIZE)
+	var id string
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			// A blank line ends the event
+			if data.Len() == 0 {
+				continue
+			}
+			var state State
+			if err := json.Unmarshal(data.Bytes(), &state); err != nil {
+				return received, fmt.Errorf("js.bet api: decoding state: %w", err)
+			}
+			data.Reset()
+			if id != "" {
+				*lastID = id
+			}
+			select {
+			case states <- state:
+				received = true
+			case <-ctx.Done():
+				return received, ctx.Err()
+			}
+		case bytes.HasPrefix(line, []byte("id: ")):
+			id = string(line[len("id: "):])
+		case bytes.HasPrefix(line, []byte("data: ")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(line[len("data: "):])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return received, err
+	}
+	return received, fmt.Errorf("js.bet api: stream closed")
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"js-bet/internal/game"
+	"time"
+)
+
+// Mirrors of the JSON views served by the server's /api/v1 endpoints
+
+// Damage multiplier of critical hits, for clients estimating how hard fighters hit
+const CRIT_MULTIPLIER = game.CRIT_MULTIPLIER
+
+type Ability struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Cooldown    int    `json:"cooldown"` // Ticks until the ability is ready
+	MaxCooldown int    `json:"maxCooldown"`
+	Priority    int    `json:"priority"`
+}
+
+type Fighter struct {
+	Name           string    `json:"name"`
+	Color          string    `json:"color"`
+	Health         int       `json:"health"`
+	MaxHealth      int       `json:"maxHealth"`
+	Damage         int       `json:"damage"`
+	Speed          int       `json:"speed"`
+	Accuracy       float32   `json:"accuracy"`
+	Dodge          float32   `json:"dodge"`
+	CritRate       float32   `json:"critRate"`
+	AttackTimer    int       `json:"attackTimer"`
+	MaxAttackTimer int       `json:"maxAttackTimer"`
+	Rating         int       `json:"rating"`
+	RatingChange   int       `json:"ratingChange"`
+	Alive          bool      `json:"alive"`
+	Abilities      []Ability `json:"abilities"`
+}
+
+type Team struct {
+	Members     []Fighter `json:"members"`
+	Placement   int       `json:"placement"`   // 1 for the winner, 0 while the team is still standing
+	Challenging bool      `json:"challenging"` // Newly drawn for the upcoming round
+}
+
+type Match struct {
+	Bracket string `json:"bracket"`
+	Round   int    `json:"round"`
+	Left    string `json:"left"`
+	Right   string `json:"right"`
+	Winner  string `json:"winner"`
+}
+
+type Tournament struct {
+	ID       int64    `json:"id"`
+	Round    int      `json:"round"`
+	Entrants []string `json:"entrants"`
+	Matches  []Match  `json:"matches"`
+	Current  int      `json:"current"`
+	Champion string   `json:"champion"`
+}
+
+type Phase string
+
+const (
+	PREROUND  Phase = "preround"
+	ROUND     Phase = "round"
+	POSTROUND Phase = "postround"
+)
+
+type State struct {
+	Frame        int         `json:"frame"`
+	Phase        Phase       `json:"phase"`
+	Countdown    int         `json:"countdown"` // Seconds left in the pre-round or post-round phase
+	Status       string      `json:"status"`
+	BettingOpen  bool        `json:"bettingOpen"`
+	Winner       string      `json:"winner"` // "left" or "right" once a duel is decided
+	TeamSize     int         `json:"teamSize"`
+	Teams        []Team      `json:"teams"`
+	Tournament   *Tournament `json:"tournament,omitempty"`
+	Events       []string    `json:"events"`       // Latest entries of the event log, oldest first
+	EventCount   int         `json:"eventCount"`   // Entries written to the event log so far, to tell which entries are new
+	Paused       bool        `json:"paused"`       // Paused by an admin
+	Announcement string      `json:"announcement"` // Message broadcast by the admins
+}
+
+// Battles with more than two sides are fought as a battle royale
+func (s State) FreeForAll() bool {
+	return len(s.Teams) > 2
+}
+
+type Market string
+
+const (
+	DUEL     Market = "duel"
+	CHAMPION Market = "champion"
+	ROYALE   Market = "royale"
+)
+
+// A bet on one of the markets, Side is used by duel bets, Fighter by champion and royale bets and Kind by royale bets
+type Bet struct {
+	Market  Market `json:"market"`
+	Side    string `json:"side,omitempty"`    // "left" or "right"
+	Fighter string `json:"fighter,omitempty"` // Name of the fighter
+	Kind    string `json:"kind,omitempty"`    // "winner" or "top3"
+	Amount  int    `json:"amount"`
+}
+
+type User struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Gold int    `json:"gold"`
+	Bot  bool   `json:"bot"`  // Accounts holding a betting API key are ranked apart from humans
+	Bets []Bet  `json:"bets"` // Bets still waiting on a result
+}
+
+type token struct {
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	RefreshToken string    `json:"refreshToken,omitempty"` // Empty when the server kept the previous one
+}
@@ -1,21 +1,38 @@
 const audioCache = {};
 
+// Sound names from the server's cue manifest mapped to their urls, filled in by loadCueManifest
+let cueSounds = {};
+
+async function loadCueManifest() {
+  try {
+    const response = await fetch('/assets/cues.json');
+    const manifest = await response.json();
+    cueSounds = manifest.sounds ?? {};
+  } catch (error) {
+    console.error('Failed to load cue manifest:', error);
+  }
+}
+
 async function preloadAudioBuffers() {
-  const audioFiles = ['attack', 'block', 'crit', 'dodge', 'winner'];
+  await loadCueManifest();
   const audioContext = new (window.AudioContext || window.webkitAudioContext)();
-  
-  for (const name of audioFiles) {
+
+  // Several cues may share a sound, only fetch and decode each file once
+  const buffersByUrl = {};
+  for (const [name, url] of Object.entries(cueSounds)) {
     try {
-      const response = await fetch(`./audio/${name}.wav`);
-      const arrayBuffer = await response.arrayBuffer();
-      const audioBuffer = await audioContext.decodeAudioData(arrayBuffer);
-      audioCache[name] = audioBuffer;
+      if (!buffersByUrl[url]) {
+        const response = await fetch(url);
+        const arrayBuffer = await response.arrayBuffer();
+        buffersByUrl[url] = await audioContext.decodeAudioData(arrayBuffer);
+      }
+      audioCache[name] = buffersByUrl[url];
       console.log(`Loaded: ${name}`);
     } catch (error) {
       console.error(`Failed to load ${name}:`, error);
     }
   }
-  
+
   window.audioContext = audioContext;
 }
 
@@ -24,33 +41,34 @@ function playAudioInstant(name) {
     console.error("AudioContext not initialized");
     return;
   }
-  
+
   const buffer = audioCache[name];
   if (!buffer) {
     console.warn(`Audio ${name} not loaded`);
     return;
   }
-  
+
   if (window.audioContext.state === 'suspended') {
     window.audioContext.resume();
   }
-  
+
   const source = window.audioContext.createBufferSource();
   source.buffer = buffer;
   source.connect(window.audioContext.destination);
   source.start(0); // Play immediately
-  
+
   console.log(`Playing: ${name} (Web Audio)`);
 }
 
 window.addEventListener("htmx:after:swap", (event) => {
   const sides = event.detail.ctx.target.querySelector("#fighter-sides");
   const audiosToPlay = sides?.dataset?.audios;
-  
-  if (!audiosToPlay) return;
-  
+
+  if (!audiosToPlay || audiosToPlay === "none") return;
+
   const names = audiosToPlay.split(",");
   for (const name of names) {
+    if (name.trim() === "") continue;
     playAudioInstant(name.trim());
   }
 });
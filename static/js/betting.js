@@ -0,0 +1,10 @@
+// Bet forms live outside of the game stream, enable them only while the server reports betting as open
+window.addEventListener("htmx:after:swap", (event) => {
+  const status = event.detail.ctx.target.querySelector("#betting-status");
+  if (!status) return;
+
+  const open = status.dataset.bettingOpen === "true";
+  for (const fields of document.querySelectorAll(".bet-fields")) {
+    fields.disabled = !open;
+  }
+});
@@ -0,0 +1,7 @@
+// Send the csrf token from its cookie with every htmx request, the server refuses state changing requests without it
+document.addEventListener("htmx:config:request", (event) => {
+  const cookie = document.cookie.split("; ").find((entry) => entry.startsWith("csrf_token="));
+  if (cookie) {
+    event.detail.ctx.request.headers["X-CSRF-Token"] = decodeURIComponent(cookie.slice("csrf_token=".length));
+  }
+});
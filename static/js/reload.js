@@ -0,0 +1,13 @@
+// Servers started with -dev send a reload event over the game stream whenever a component or static file changes
+document.getElementById("game")?.addEventListener("reload", (event) => {
+  const styles = JSON.parse(event.detail?.data || "{}").styles;
+  if (!styles) {
+    location.reload();
+    return;
+  }
+  // Only stylesheets changed, swap them in place so the page keeps its state
+  for (const link of document.querySelectorAll('link[rel="stylesheet"]')) {
+    const url = styles[new URL(link.href).pathname];
+    if (url) link.href = url;
+  }
+});
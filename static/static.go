@@ -0,0 +1,7 @@
+// Files served by the site, embedded so the server runs from any directory
+package static
+
+import "embed"
+
+//go:embed index.html cues.json styles js icons audio
+var Files embed.FS